@@ -2,16 +2,23 @@ package main
 
 import (
 	"fmt"
+	"iss/internal/events"
 	"iss/internal/models"
 	"iss/internal/service"
 	"os"
 )
 
 func main() {
-	issueService := service.NewIssueService()
-	agentService := service.NewAgentService()
+	issueService := service.NewIssueService(nil)
+	agentService := service.NewAgentService(nil)
 	assignmentStrategy := service.GetAssignmentStrategy(service.FreeAgentFirst)
-	resolutionService := service.NewResolutionService(issueService, agentService, assignmentStrategy)
+	bus := events.NewBus()
+	// the subscriber runs on its own worker, the counts are only read once the bus has been closed
+	published := make(map[events.EventType]int)
+	bus.SubscribeAsync(func(e events.Event) {
+		published[e.Type]++
+	}, 1, 64, events.IssueCreated, events.IssueAssigned, events.IssueWaitlisted, events.IssueStatusChanged, events.IssueResolved, events.PendingIssuePromoted, events.AgentAdded)
+	resolutionService := service.NewResolutionService(issueService, agentService, assignmentStrategy, nil, service.WithEventBus(bus))
 
 	// scenario 1: 8 tasks for 4 agents, 4 picked, 4 queued
 	fmt.Println("scenario 1: 8 tasks for 4 agents, 4 picked, 4 queued")
@@ -22,14 +29,14 @@ func main() {
 		{TxnId: "T4", Subject: "Purchase Failed", Description: "Unable to purchase Mutual Fund", Email: "testUser1@test.com", Type: models.Payment},
 		{TxnId: "T5", Subject: "Payment Failed", Description: "My payment failed but money is debited", Email: "testUser1@test.com", Type: models.Payment},
 		{TxnId: "T6", Subject: "Purchase Failed", Description: "Unable to purchase Mutual Fund", Email: "testUser2@test.com", Type: models.MutualFund},
-		{TxnId: "T7", Subject: "Payment Failed", Description: "My payment failed but money is debited", Email: "testUser1@test.com", Type: models.Payment},
+		{TxnId: "T7", Subject: "Payment Failed", Description: "My payment failed but money is debited", Email: "testUser1@test.com", Type: models.Payment, Priority: models.P0},
 		{TxnId: "T8", Subject: "Purchase Failed", Description: "Unable to purchase Mutual Fund", Email: "testUser2@test.com", Type: models.MutualFund},
 	}
 	activeIssueIds := make([]string, 0)
 	fmt.Println("Creating issues...")
 	for i := range testIssues {
 		issue := &testIssues[i]
-		id, err := resolutionService.CreateIssue(issue.TxnId, issue.Subject, issue.Description, issue.Email, issue.Type)
+		id, err := resolutionService.CreateIssue(issue.TxnId, issue.Subject, issue.Description, issue.Email, issue.Type, issue.Priority)
 		if err != nil {
 			fmt.Println("Error occurred - CreateIssue:", err)
 			os.Exit(1)
@@ -39,15 +46,15 @@ func main() {
 	}
 
 	testAgents := []models.Agent{
-		{Email: "agent1@test.com", Name: "Agent 1", Expertise: map[models.IssueType]bool{models.Payment: true}},
-		{Email: "agent2@test.com", Name: "Agent 2", Expertise: map[models.IssueType]bool{models.Payment: true, models.MutualFund: true}},
-		{Email: "agent3@test.com", Name: "Agent 3", Expertise: map[models.IssueType]bool{models.MutualFund: true}},
-		{Email: "agent4@test.com", Name: "Agent 4", Expertise: map[models.IssueType]bool{models.MutualFund: true}},
+		{Email: "agent1@test.com", Name: "Agent 1", Expertise: map[models.IssueType]models.Skill{models.Payment: {Level: models.Senior}}},
+		{Email: "agent2@test.com", Name: "Agent 2", Expertise: map[models.IssueType]models.Skill{models.Payment: {Level: models.Junior}, models.MutualFund: {Level: models.Specialist}}},
+		{Email: "agent3@test.com", Name: "Agent 3", Expertise: map[models.IssueType]models.Skill{models.MutualFund: {Level: models.Trainee}}},
+		{Email: "agent4@test.com", Name: "Agent 4", Expertise: map[models.IssueType]models.Skill{models.MutualFund: {Level: models.Junior}}},
 	}
 	fmt.Println("\nAdding agents...")
 	for i := range testAgents {
 		agent := &testAgents[i]
-		id, err := resolutionService.AddAgent(agent.Email, agent.Name, agent.Expertise)
+		id, err := resolutionService.AddAgent(agent.Email, agent.Name, agent.Expertise, agent.Capacity)
 		if err != nil {
 			fmt.Println("Error Occurred - AddAgent:", err)
 		}
@@ -66,17 +73,17 @@ func main() {
 		} else {
 			fmt.Printf("Issue %s assigned to Agent %s\n", issueId, agentId)
 		}
-		if issueId == "IT2" {
+		if issueId == "I2" {
 			fmt.Println(resolutionService.AgentService.AvailableAgentsByExpertise)
 		}
 	}
 
 	fmt.Println()
 	fmt.Println()
-	// scenario 2: IT's assigned agent (A1/A2 as ordering is not guranteed in the mapping) should have min queue length & top of the heap after IT1 is resolved
+	// scenario 2: IT's assigned agent (A1/A2 as ordering is not guranteed in the mapping) should have min queue length & top of the heap after I1 is resolved
 	// hence T9 should be added to pending list of IT's assigned agent (A1/A2)
-	fmt.Println("scenario 2: IT's assigned agent (A1/A2 as ordering is not guranteed in the mapping) should have min queue length & top of the heap after IT1 is resolved hence T9 should be added to pending list of IT's assigned agent (A1/A2)")
-	issueId := "IT1"
+	fmt.Println("scenario 2: IT's assigned agent (A1/A2 as ordering is not guranteed in the mapping) should have min queue length & top of the heap after I1 is resolved hence T9 should be added to pending list of IT's assigned agent (A1/A2)")
+	issueId := "I1"
 	err := resolutionService.ResolveIssue(issueId, "random-message")
 	if err != nil {
 		fmt.Printf("Error resolving issue %s: %v\n", issueId, err)
@@ -84,7 +91,7 @@ func main() {
 		fmt.Printf("Issue %s resolved\n", issueId)
 	}
 
-	id, err := resolutionService.CreateIssue("T9", "a", "a", "a", models.Payment)
+	id, err := resolutionService.CreateIssue("T9", "a", "a", "a", models.Payment, models.P3)
 	if err != nil {
 		fmt.Println("Error occurred - CreateIssue:", err)
 		os.Exit(1)
@@ -102,9 +109,9 @@ func main() {
 	fmt.Println()
 	fmt.Println()
 
-	// scenario 3: After resolving all issues for A2 (initially assigned IT2), A2 should be in AvailableAgentsByExpertise and take a new Payment task
-	fmt.Println("Scenario 3: After resolving all issues for the agent initially assigned IT2 (e.g., A2), that agent should return to AvailableAgentsByExpertise and take a new Payment task (IT10)")
-	issueId = "IT2"
+	// scenario 3: After resolving all issues for A2 (initially assigned I2), A2 should be in AvailableAgentsByExpertise and take a new Payment task
+	fmt.Println("Scenario 3: After resolving all issues for the agent initially assigned I2 (e.g., A2), that agent should return to AvailableAgentsByExpertise and take a new Payment task (I10)")
+	issueId = "I2"
 	err = resolutionService.ResolveIssue(issueId, "MutualFund issue fixed")
 	if err != nil {
 		fmt.Printf("Error resolving issue %s: %v\n", issueId, err)
@@ -112,7 +119,7 @@ func main() {
 		fmt.Printf("Issue %s resolved\n", issueId)
 	}
 
-	issueId = "IT7"
+	issueId = "I7"
 	err = resolutionService.ResolveIssue(issueId, "Payment reversed")
 	if err != nil {
 		fmt.Printf("Error resolving issue %s: %v\n", issueId, err)
@@ -120,7 +127,7 @@ func main() {
 		fmt.Printf("Issue %s resolved\n", issueId)
 	}
 
-	id, err = resolutionService.CreateIssue("T10", "Payment Failed", "Test payment issue", "testUser3@test.com", models.Payment)
+	id, err = resolutionService.CreateIssue("T10", "Payment Failed", "Test payment issue", "testUser3@test.com", models.Payment, models.P1)
 	if err != nil {
 		fmt.Println("Error occurred - CreateIssue:", err)
 		os.Exit(1)
@@ -135,15 +142,135 @@ func main() {
 		fmt.Printf("Issue %s assigned to Agent %s\n", id, agentId)
 	}
 
+	fmt.Println()
+	fmt.Println()
+
+	// scenario 4: an agent with capacity 2 works on two Insurance issues at once, resolving either one frees a slot
+	fmt.Println("scenario 4: an agent with capacity 2 works on two Insurance issues at once, resolving either one frees a slot")
+	agentId, err = resolutionService.AddAgent("agent5@test.com", "Agent 5", map[models.IssueType]models.Skill{models.Insurance: {Level: models.Senior}}, 2)
+	if err != nil {
+		fmt.Println("Error Occurred - AddAgent:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Agent %s created\n", agentId)
+	for _, txnId := range []string{"T11", "T12"} {
+		id, err = resolutionService.CreateIssue(txnId, "Claim Pending", "Insurance claim not settled", "testUser4@test.com", models.Insurance, models.P2)
+		if err != nil {
+			fmt.Println("Error occurred - CreateIssue:", err)
+			os.Exit(1)
+		}
+		agentId, waitlisted, err = resolutionService.AssignIssue(id)
+		if err != nil {
+			fmt.Println("error occurred - AssignIssue", id)
+		} else if waitlisted {
+			fmt.Printf("Issue %s added to waitlist of Agent %s\n", id, agentId)
+		} else {
+			fmt.Printf("Issue %s assigned to Agent %s\n", id, agentId)
+		}
+	}
+	issueId = "I11"
+	if err = resolutionService.ResolveIssue(issueId, "Claim settled"); err != nil {
+		fmt.Printf("Error resolving issue %s: %v\n", issueId, err)
+	} else {
+		fmt.Printf("Issue %s resolved\n", issueId)
+	}
+
+	fmt.Println()
+	fmt.Println()
+
+	// scenario 5: an agent going offline keeps its active issue but its pending issues are handed to other agents
+	fmt.Println("scenario 5: an agent going offline keeps its active issue but its pending issues are handed to other agents")
+	for _, agentId := range []string{"A3", "A4"} {
+		agent := resolutionService.AgentService.GetAgent(agentId)
+		fmt.Printf("Agent %s has %d pending issues\n", agentId, len(agent.GetPendingIssues()))
+	}
+	moved, err := resolutionService.SetAgentPresence("A3", models.Offline)
+	if err != nil {
+		fmt.Println("Error occurred - SetAgentPresence:", err)
+	}
+	for issueId, agentId := range moved {
+		fmt.Printf("Issue %s moved to Agent %s\n", issueId, agentId)
+	}
+
+	fmt.Println()
+	fmt.Println()
+
+	// scenario 6: a deactivated agent hands over its active and pending issues and keeps its work history
+	fmt.Println("scenario 6: a deactivated agent hands over its active and pending issues and keeps its work history")
+	moved, err = resolutionService.DeactivateAgent("A4")
+	if err != nil {
+		fmt.Println("Error occurred - DeactivateAgent:", err)
+	}
+	for issueId, agentId := range moved {
+		fmt.Printf("Issue %s handed over to Agent %s\n", issueId, agentId)
+	}
+
+	fmt.Println()
+	fmt.Println()
+
+	// scenario 7: a supervisor moves I12 from A5 to A2, the move shows up in the issue's timeline
+	fmt.Println("scenario 7: a supervisor moves I12 from A5 to A2, the move shows up in the issue's timeline")
+	issueId = "I12"
+	waitlisted, err = resolutionService.ReassignIssue(issueId, "A2", "customer also holds a mutual fund")
+	if err != nil {
+		fmt.Println("Error occurred - ReassignIssue:", err)
+	} else if waitlisted {
+		fmt.Printf("Issue %s added to waitlist of Agent %s\n", issueId, "A2")
+	} else {
+		fmt.Printf("Issue %s assigned to Agent %s\n", issueId, "A2")
+	}
+	if err = resolutionService.AddComment(issueId, models.InternalNote, "A2", "checking the linked mutual fund folio"); err != nil {
+		fmt.Println("Error occurred - AddComment:", err)
+	}
+	timeline, err := resolutionService.GetTimeline(issueId)
+	if err != nil {
+		fmt.Println("Error occurred - GetTimeline:", err)
+	}
+	for _, entry := range timeline {
+		fmt.Printf("%s by %s: %+v\n", entry.Kind, entry.Author, entry)
+	}
+
+	fmt.Println()
+	fmt.Println()
+
+	// scenario 8: a second complaint about transaction T3, which still has an open issue, is linked to it rather than replacing it
+	fmt.Println("scenario 8: a second complaint about transaction T3, which still has an open issue, is linked to it rather than replacing it")
+	id, err = resolutionService.CreateIssue("T3", "Payment Failed", "Still no refund for the failed payment", "testUser2@test.com", models.Payment, models.P1)
+	if err != nil {
+		fmt.Println("Error occurred - CreateIssue:", err)
+		os.Exit(1)
+	}
+	duplicate := resolutionService.GetIssues(map[string]string{"id": id})[0]
+	fmt.Printf("Issue %s created as a duplicate of %s\n", id, duplicate.DuplicateOf)
+
 	fmt.Println("\nGetting issues for testUser2@test.com")
 	issues := resolutionService.GetIssues(map[string]string{"email": "testUser2@test.com"})
 	for _, issue := range issues {
 		fmt.Printf("%v\n", issue)
 	}
 
+	fmt.Println("\nViewing testUser2@test.com's issue history...")
+	customer, err := resolutionService.GetCustomerByEmail("testUser2@test.com")
+	if err != nil {
+		fmt.Println("Error occurred - GetCustomerByEmail:", err)
+		os.Exit(1)
+	}
+	customerHistory, err := resolutionService.CustomerIssues(customer.Id, service.IssueQuery{})
+	if err != nil {
+		fmt.Println("Error occurred - CustomerIssues:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Customer %s has %d open issues, by status: %v\n", customer.Id, customerHistory.OpenIssues, customerHistory.Counts)
+
 	fmt.Println("\nViewing agents' work history...")
 	history := resolutionService.ViewAgentsWorkHistory()
-	for agentID, resolvedIssues := range history {
-		fmt.Printf("%s -> %v\n", agentID, resolvedIssues)
+	for agentID, work := range history {
+		fmt.Printf("%s -> %v reopened: %v\n", agentID, work.ResolvedIssues, work.ReopenedIssues)
+	}
+
+	bus.Close()
+	fmt.Println("\nLifecycle events published...")
+	for _, eventType := range []events.EventType{events.AgentAdded, events.IssueCreated, events.IssueAssigned, events.IssueWaitlisted, events.IssueStatusChanged, events.IssueResolved, events.PendingIssuePromoted} {
+		fmt.Printf("%s: %d\n", eventType, published[eventType])
 	}
 }
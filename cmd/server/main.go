@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"iss/internal/api"
+	"iss/internal/events"
+	"iss/internal/notify"
+	"iss/internal/repository"
+	"iss/internal/service"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	_ "time/tzdata" // shift time zones must resolve on hosts without a zoneinfo database
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address the http server listens on")
+	dataDir := flag.String("data", "", "directory to persist issues and agents in, kept in memory when empty")
+	walPath := flag.String("wal", "", "event log to record every transition in and replay on startup, replaces -data")
+	strategyName := flag.String("strategy", service.FreeAgentFirst.String(), "assignment strategy: free-agent-first, least-loaded, weighted-least-loaded, strict-expertise, round-robin, proficiency or tier-aware")
+	vipAgents := flag.String("vip-agents", "", "comma separated IDs of the agents dedicated to VIP customers under tier-aware, the most proficient experts when empty")
+	slaInterval := flag.Duration("sla-interval", time.Minute, "how often issues are checked for SLA escalation, 0 disables it")
+	slaReassign := flag.Bool("sla-reassign", false, "move escalated issues out of busy agents' queues to a free agent")
+	idsName := flag.String("ids", service.SequenceIDs.String(), "how issue and agent IDs are generated: sequence, uuid or ulid")
+	duplicatesName := flag.String("duplicates", service.DuplicateLink.String(), "what to do with a complaint about a transaction that has an open issue: link, reject or merge")
+	shiftInterval := flag.Duration("shift-interval", time.Minute, "how often agents are taken online and offline by their shifts, 0 disables it")
+	webhookDir := flag.String("webhooks", "", "directory to persist webhook subscriptions and dead letters in, defaults to -data and memory without it")
+	notifierName := flag.String("notifier", "none", "how customers are emailed about their issues: none, console, file or smtp")
+	notifyFile := flag.String("notify-file", "notifications.log", "file the emails are appended to with -notifier file")
+	smtpAddr := flag.String("smtp-addr", "localhost:25", "host:port of the mail server used with -notifier smtp")
+	smtpFrom := flag.String("smtp-from", "support@localhost", "sender of the emails sent with -notifier smtp")
+	smtpUser := flag.String("smtp-user", "", "username to log in to the mail server with, the password is read from SMTP_PASSWORD")
+	flag.Parse()
+
+	if *dataDir != "" && *walPath != "" {
+		fmt.Println("-data and -wal are mutually exclusive")
+		os.Exit(1)
+	}
+
+	var (
+		issueRepo      repository.IssueRepository
+		agentRepo      repository.AgentRepository
+		assignmentRepo repository.AssignmentRepository
+		customerRepo   repository.CustomerRepository
+		webhookRepo    repository.WebhookRepository
+		deadLetterRepo repository.DeadLetterRepository
+	)
+	if *dataDir != "" {
+		store, err := repository.NewFileStore(*dataDir)
+		if err != nil {
+			fmt.Println("error occurred - NewFileStore:", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		issueRepo, agentRepo, assignmentRepo, customerRepo = store.Issues, store.Agents, store.Assignments, store.Customers
+		webhookRepo, deadLetterRepo = store.Webhooks, store.DeadLetters
+	}
+	if *webhookDir != "" {
+		if err := os.MkdirAll(*webhookDir, 0o755); err != nil {
+			fmt.Println("error occurred - MkdirAll:", err)
+			os.Exit(1)
+		}
+		var err error
+		if webhookRepo, err = repository.NewFileWebhookRepository(filepath.Join(*webhookDir, "webhooks.jsonl")); err != nil {
+			fmt.Println("error occurred - NewFileWebhookRepository:", err)
+			os.Exit(1)
+		}
+		if deadLetterRepo, err = repository.NewFileDeadLetterRepository(filepath.Join(*webhookDir, "dead_letters.jsonl")); err != nil {
+			fmt.Println("error occurred - NewFileDeadLetterRepository:", err)
+			os.Exit(1)
+		}
+	}
+
+	issueService := service.NewIssueService(issueRepo)
+	agentService := service.NewAgentService(agentRepo)
+	strategy, err := service.ParseAssignmentStrategy(*strategyName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	assignmentStrategy := service.GetAssignmentStrategy(strategy)
+	if strategy == service.TierAware && *vipAgents != "" {
+		assignmentStrategy = service.NewTierAwareStrategy(service.NewProficiencyStrategy(), strings.Split(*vipAgents, ",")...)
+	}
+	ids, err := service.ParseIDGenerator(*idsName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	duplicates, err := service.ParseDuplicatePolicy(*duplicatesName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	idGenerator := service.GetIDGenerator(ids)
+	bus := events.NewBus()
+	defer bus.Close()
+	opts := []service.Option{
+		service.WithIDGenerator(idGenerator),
+		service.WithEventBus(bus),
+		service.WithDuplicatePolicy(duplicates),
+		service.WithCustomerService(service.NewCustomerService(customerRepo)),
+	}
+	if *walPath != "" {
+		eventLog, err := events.OpenFileLog(*walPath)
+		if err != nil {
+			fmt.Println("error occurred - OpenFileLog:", err)
+			os.Exit(1)
+		}
+		defer eventLog.Close()
+		opts = append(opts, service.WithEventLog(eventLog))
+	}
+	resolutionService := service.NewResolutionService(issueService, agentService, assignmentStrategy, assignmentRepo, opts...)
+	if err := resolutionService.Recover(); err != nil {
+		fmt.Println("error occurred - Recover:", err)
+		os.Exit(1)
+	}
+
+	// webhooks only hear about transitions made from now on, the replayed ones have been delivered before
+	webhookService := service.NewWebhookService(issueService, webhookRepo, deadLetterRepo)
+	webhookService.SetIDGenerator(idGenerator)
+	webhookService.Subscribe(bus)
+	defer webhookService.Close()
+
+	var notifier notify.Notifier
+	switch *notifierName {
+	case "none":
+	case "console":
+		notifier = notify.NewConsoleNotifier()
+	case "file":
+		fileNotifier, file, err := notify.NewFileNotifier(*notifyFile)
+		if err != nil {
+			fmt.Println("error occurred - NewFileNotifier:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		notifier = fileNotifier
+	case "smtp":
+		notifier = notify.NewSMTPNotifier(*smtpAddr, *smtpFrom, *smtpUser, os.Getenv("SMTP_PASSWORD"))
+	default:
+		fmt.Printf("unknown notifier %q\n", *notifierName)
+		os.Exit(1)
+	}
+	if notifier != nil {
+		service.NewNotificationService(resolutionService, notifier, nil).Subscribe(bus)
+	}
+
+	if *slaInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go service.NewSLAMonitor(resolutionService, *slaInterval, *slaReassign).Run(ctx)
+	}
+	if *shiftInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go service.NewShiftMonitor(resolutionService, *shiftInterval).Run(ctx)
+	}
+
+	fmt.Printf("listening on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, api.NewServer(resolutionService, api.WithWebhooks(webhookService))); err != nil {
+		fmt.Println("error occurred - ListenAndServe:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"iss/internal/models"
+	"net/http"
+)
+
+type customerRequest struct {
+	Emails         []string            `json:"emails"`
+	Phone          string              `json:"phone"`
+	Tier           models.CustomerTier `json:"tier"`
+	Locale         string              `json:"locale"`
+	OpenIssueLimit int                 `json:"open_issue_limit"`
+}
+
+func (s *Server) addCustomer(w http.ResponseWriter, r *http.Request) {
+	var req customerRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := s.rs.AddCustomer(req.Emails, req.Phone, req.Tier, req.Locale, req.OpenIssueLimit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+// findCustomer looks a customer up by the email query parameter
+func (s *Server) findCustomer(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		writeError(w, fmt.Errorf("%w: email is required", errBadRequest))
+		return
+	}
+	customer, err := s.rs.GetCustomerByEmail(email)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, customer.Snapshot())
+}
+
+func (s *Server) getCustomer(w http.ResponseWriter, r *http.Request) {
+	customer, err := s.rs.GetCustomer(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, customer.Snapshot())
+}
+
+func (s *Server) updateCustomer(w http.ResponseWriter, r *http.Request) {
+	var req customerRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.UpdateCustomer(r.PathValue("id"), req.Emails, req.Phone, req.Tier, req.Locale, req.OpenIssueLimit); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCustomerIssues takes the same query parameters as GET /issues, see parseIssueQuery
+func (s *Server) getCustomerIssues(w http.ResponseWriter, r *http.Request) {
+	q, err := parseIssueQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	history, err := s.rs.CustomerIssues(r.PathValue("id"), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	history.Customer = history.Customer.Snapshot()
+	history.Issues.Issues = snapshots(history.Issues.Issues)
+	writeJSON(w, http.StatusOK, history)
+}
+
+type optOutRequest struct {
+	OptOut bool `json:"opt_out"`
+}
+
+func (s *Server) setCustomerOptOut(w http.ResponseWriter, r *http.Request) {
+	var req optOutRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.SetCustomerOptOut(r.PathValue("id"), req.OptOut); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
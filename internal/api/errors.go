@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"iss/internal/models"
+	"iss/internal/service"
+	"net/http"
+)
+
+// statusFor maps domain errors to the http status code returned to the client
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, service.ErrIssueNotFound), errors.Is(err, service.ErrAgentNotFound),
+		errors.Is(err, service.ErrCustomerNotFound), errors.Is(err, service.ErrWebhookNotFound),
+		errors.Is(err, service.ErrDeadLetterNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrAgentBusy),
+		errors.Is(err, service.ErrIssueNotAssigned),
+		errors.Is(err, service.ErrIssueAlreadyAssigned),
+		errors.Is(err, service.ErrIssueNotActive),
+		errors.Is(err, service.ErrNoAgentAvailable),
+		errors.Is(err, service.ErrAgentDeactivated),
+		errors.Is(err, service.ErrAgentUnavailable),
+		errors.Is(err, service.ErrDuplicateTransaction),
+		errors.Is(err, service.ErrOpenIssueLimit),
+		errors.Is(err, models.ErrInvalidTransition):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInvalidIssue), errors.Is(err, models.ErrInvalidAgent), errors.Is(err, models.ErrInvalidCustomer),
+		errors.Is(err, models.ErrInvalidWebhook), errors.Is(err, service.ErrStatusManaged), errors.Is(err, service.ErrInvalidQuery),
+		errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrDeliveryFailed):
+		return http.StatusBadGateway
+	case errors.Is(err, service.ErrEventLogFailed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+var errBadRequest = errors.New("bad request")
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusFor(err), errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"iss/internal/models"
+	"iss/internal/service"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parseIssueQuery builds an IssueQuery from the query string of GET /issues. List parameters take comma separated
+// values and may be repeated, times are unix seconds, unknown parameters are rejected so that typos do not
+// silently widen the result.
+func parseIssueQuery(values url.Values) (service.IssueQuery, error) {
+	var q service.IssueQuery
+	for key := range values {
+		var err error
+		switch key {
+		case "id":
+			q.Ids = list(values, key)
+		case "txn_id":
+			q.TxnIds = list(values, key)
+		case "email":
+			q.Emails = list(values, key)
+		case "agent_id":
+			q.AgentIds = list(values, key)
+		case "customer_id":
+			q.CustomerIds = list(values, key)
+		case "type":
+			q.Types, err = parseList(list(values, key), models.ParseIssueType)
+		case "priority":
+			q.Priorities, err = parseList(list(values, key), models.ParsePriority)
+		case "status":
+			q.Statuses, err = parseList(list(values, key), models.ParseIssueStatus)
+		case "reopened":
+			var reopened bool
+			reopened, err = strconv.ParseBool(values.Get(key))
+			q.Reopened = &reopened
+		case "created_from":
+			q.CreatedAt.From, err = parseUnix(values.Get(key))
+		case "created_to":
+			q.CreatedAt.To, err = parseUnix(values.Get(key))
+		case "updated_from":
+			q.UpdatedAt.From, err = parseUnix(values.Get(key))
+		case "updated_to":
+			q.UpdatedAt.To, err = parseUnix(values.Get(key))
+		case "subject_contains":
+			q.SubjectContains = values.Get(key)
+		case "subject_prefix":
+			q.SubjectPrefix = values.Get(key)
+		case "description_contains":
+			q.DescriptionContains = values.Get(key)
+		case "description_prefix":
+			q.DescriptionPrefix = values.Get(key)
+		case "sort":
+			q.SortBy = service.IssueSortField(strings.ToLower(values.Get(key)))
+		case "order":
+			switch strings.ToLower(values.Get(key)) {
+			case "asc", "":
+			case "desc":
+				q.Descending = true
+			default:
+				err = fmt.Errorf("unknown order %q", values.Get(key))
+			}
+		case "limit":
+			q.Limit, err = strconv.Atoi(values.Get(key))
+		case "cursor":
+			q.Cursor = values.Get(key)
+		default:
+			err = fmt.Errorf("unknown query parameter %q", key)
+		}
+		if err != nil {
+			return q, fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+	}
+	return q, nil
+}
+
+// list splits every value of the parameter on commas, dropping empty items
+func list(values url.Values, key string) []string {
+	items := make([]string, 0)
+	for _, value := range values[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func parseList[T any](items []string, parse func(string) (T, error)) ([]T, error) {
+	parsed := make([]T, 0, len(items))
+	for _, item := range items {
+		value, err := parse(item)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, value)
+	}
+	return parsed, nil
+}
+
+func parseUnix(s string) (int64, error) {
+	at, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid unix time %q", s)
+	}
+	return at, nil
+}
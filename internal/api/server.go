@@ -0,0 +1,407 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"iss/internal/models"
+	"iss/internal/service"
+	"net/http"
+)
+
+// Server exposes the ResolutionService over HTTP with JSON request and response bodies
+type Server struct {
+	rs       *service.ResolutionService
+	webhooks *service.WebhookService
+	mux      *http.ServeMux
+}
+
+func NewServer(rs *service.ResolutionService, opts ...ServerOption) *Server {
+	s := &Server{
+		rs:  rs,
+		mux: http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("POST /issues", s.createIssue)
+	s.mux.HandleFunc("GET /issues", s.getIssues)
+	s.mux.HandleFunc("GET /issues/{id}", s.getIssue)
+	s.mux.HandleFunc("GET /search", s.search)
+	s.mux.HandleFunc("GET /unassigned/{type}", s.getUnassignedIssues)
+	s.mux.HandleFunc("PATCH /issues/{id}", s.updateIssue)
+	s.mux.HandleFunc("POST /issues/{id}/assign", s.assignIssue)
+	s.mux.HandleFunc("POST /issues/{id}/resolve", s.resolveIssue)
+	s.mux.HandleFunc("POST /issues/{id}/reopen", s.reopenIssue)
+	s.mux.HandleFunc("POST /issues/{id}/reassign", s.reassignIssue)
+	s.mux.HandleFunc("POST /issues/{id}/cancel", s.cancelIssue)
+	s.mux.HandleFunc("POST /issues/{id}/comments", s.addComment)
+	s.mux.HandleFunc("GET /issues/{id}/timeline", s.getTimeline)
+	s.mux.HandleFunc("GET /sla/at-risk", s.getAtRiskIssues)
+	s.mux.HandleFunc("GET /sla/breached", s.getBreachedIssues)
+	s.mux.HandleFunc("POST /agents", s.addAgent)
+	s.mux.HandleFunc("GET /agents/history", s.viewAgentsWorkHistory)
+	s.mux.HandleFunc("PUT /agents/{id}/presence", s.setAgentPresence)
+	s.mux.HandleFunc("PUT /agents/{id}/shifts", s.setAgentShifts)
+	s.mux.HandleFunc("POST /agents/{id}/deactivate", s.deactivateAgent)
+	s.mux.HandleFunc("POST /agents/{id}/activate", s.activateAgent)
+	s.mux.HandleFunc("DELETE /agents/{id}", s.removeAgent)
+	s.mux.HandleFunc("POST /customers", s.addCustomer)
+	s.mux.HandleFunc("GET /customers", s.findCustomer)
+	s.mux.HandleFunc("GET /customers/{id}", s.getCustomer)
+	s.mux.HandleFunc("PUT /customers/{id}", s.updateCustomer)
+	s.mux.HandleFunc("GET /customers/{id}/issues", s.getCustomerIssues)
+	s.mux.HandleFunc("PUT /customers/{id}/notifications", s.setCustomerOptOut)
+	if s.webhooks != nil {
+		s.routeWebhooks()
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type createIssueRequest struct {
+	TxnId       string           `json:"txn_id"`
+	Type        models.IssueType `json:"type"`
+	Priority    models.Priority  `json:"priority"`
+	Subject     string           `json:"subject"`
+	Description string           `json:"description"`
+	Email       string           `json:"email"`
+}
+
+type idResponse struct {
+	Id string `json:"id"`
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, r *http.Request) {
+	var req createIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := s.rs.CreateIssue(req.TxnId, req.Subject, req.Description, req.Email, req.Type, req.Priority)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+// getIssues returns one page of the issues matching the query parameters, see parseIssueQuery
+func (s *Server) getIssues(w http.ResponseWriter, r *http.Request) {
+	q, err := parseIssueQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := s.rs.QueryIssues(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page.Issues = snapshots(page.Issues)
+	writeJSON(w, http.StatusOK, page)
+}
+
+type searchResponse struct {
+	Hits []service.IssueHit `json:"hits"`
+}
+
+// search ranks the issues matching the filters in the query parameters, see parseIssueQuery, by how well
+// they match the text in q
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	text := values.Get("q")
+	values.Del("q")
+	filter, err := parseIssueQuery(values)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limit := filter.Limit
+	filter.Limit = 0
+	hits, err := s.rs.Search(text, filter, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := searchResponse{Hits: make([]service.IssueHit, 0, len(hits))}
+	for _, hit := range hits {
+		resp.Hits = append(resp.Hits, service.IssueHit{Issue: hit.Issue.Snapshot(), Score: hit.Score})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, r *http.Request) {
+	issues := s.rs.GetIssues(map[string]string{"id": r.PathValue("id")})
+	if len(issues) == 0 {
+		writeError(w, service.ErrIssueNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, issues[0].Snapshot())
+}
+
+func (s *Server) getUnassignedIssues(w http.ResponseWriter, r *http.Request) {
+	issueType, err := models.ParseIssueType(r.PathValue("type"))
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshots(s.rs.GetUnassignedIssues(issueType)))
+}
+
+type updateIssueRequest struct {
+	Status     models.IssueStatus `json:"status"`
+	Resolution string             `json:"resolution"`
+}
+
+func (s *Server) updateIssue(w http.ResponseWriter, r *http.Request) {
+	var req updateIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.UpdateIssue(r.PathValue("id"), req.Resolution, req.Status); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type assignIssueResponse struct {
+	AgentId    string `json:"agent_id"`
+	Waitlisted bool   `json:"waitlisted"`
+}
+
+func (s *Server) assignIssue(w http.ResponseWriter, r *http.Request) {
+	agentId, waitlisted, err := s.rs.AssignIssue(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assignIssueResponse{AgentId: agentId, Waitlisted: waitlisted})
+}
+
+type resolveIssueRequest struct {
+	Resolution string `json:"resolution"`
+}
+
+func (s *Server) resolveIssue(w http.ResponseWriter, r *http.Request) {
+	var req resolveIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.ResolveIssue(r.PathValue("id"), req.Resolution); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reopenIssueRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) reopenIssue(w http.ResponseWriter, r *http.Request) {
+	var req reopenIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	agentId, waitlisted, err := s.rs.ReopenIssue(r.PathValue("id"), req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assignIssueResponse{AgentId: agentId, Waitlisted: waitlisted})
+}
+
+type reassignIssueRequest struct {
+	AgentId string `json:"agent_id"`
+	Reason  string `json:"reason"`
+}
+
+func (s *Server) reassignIssue(w http.ResponseWriter, r *http.Request) {
+	var req reassignIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	waitlisted, err := s.rs.ReassignIssue(r.PathValue("id"), req.AgentId, req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assignIssueResponse{AgentId: req.AgentId, Waitlisted: waitlisted})
+}
+
+type cancelIssueRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) cancelIssue(w http.ResponseWriter, r *http.Request) {
+	var req cancelIssueRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.CancelIssue(r.PathValue("id"), req.Reason); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type addCommentRequest struct {
+	Kind   models.EntryKind `json:"kind"` // Comment, CustomerReply or InternalNote, defaults to Comment
+	Author string           `json:"author"`
+	Body   string           `json:"body"`
+}
+
+func (s *Server) addComment(w http.ResponseWriter, r *http.Request) {
+	var req addCommentRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.AddComment(r.PathValue("id"), req.Kind, req.Author, req.Body); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) getTimeline(w http.ResponseWriter, r *http.Request) {
+	timeline, err := s.rs.GetTimeline(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, timeline)
+}
+
+func (s *Server) getAtRiskIssues(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rs.GetAtRiskIssues())
+}
+
+func (s *Server) getBreachedIssues(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rs.GetBreachedIssues())
+}
+
+type addAgentRequest struct {
+	Name      string                            `json:"name"`
+	Email     string                            `json:"email"`
+	Expertise map[models.IssueType]models.Skill `json:"expertise"`
+	Capacity  int                               `json:"capacity"`
+}
+
+func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
+	var req addAgentRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := s.rs.AddAgent(req.Email, req.Name, req.Expertise, req.Capacity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (s *Server) viewAgentsWorkHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rs.ViewAgentsWorkHistory())
+}
+
+type setAgentPresenceRequest struct {
+	Presence models.Presence `json:"presence"`
+}
+
+type handOverResponse struct {
+	Moved map[string]string `json:"moved"` // new agent of every issue that was handed over, empty when parked
+}
+
+func (s *Server) setAgentPresence(w http.ResponseWriter, r *http.Request) {
+	var req setAgentPresenceRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	moved, err := s.rs.SetAgentPresence(r.PathValue("id"), req.Presence)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, handOverResponse{Moved: moved})
+}
+
+type setAgentShiftsRequest struct {
+	Shifts []models.Shift `json:"shifts"`
+}
+
+func (s *Server) setAgentShifts(w http.ResponseWriter, r *http.Request) {
+	var req setAgentShiftsRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.rs.SetAgentShifts(r.PathValue("id"), req.Shifts); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deactivateAgent(w http.ResponseWriter, r *http.Request) {
+	moved, err := s.rs.DeactivateAgent(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, handOverResponse{Moved: moved})
+}
+
+func (s *Server) activateAgent(w http.ResponseWriter, r *http.Request) {
+	if err := s.rs.ActivateAgent(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeAgent(w http.ResponseWriter, r *http.Request) {
+	moved, err := s.rs.RemoveAgent(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, handOverResponse{Moved: moved})
+}
+
+// snapshots copies the issues under their locks, handlers never encode a live issue as it may change meanwhile
+func snapshots(issues []*models.Issue) []*models.Issue {
+	copies := make([]*models.Issue, 0, len(issues))
+	for _, issue := range issues {
+		copies = append(copies, issue.Snapshot())
+	}
+	return copies
+}
+
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Println("error occurred - writing response", err)
+	}
+}
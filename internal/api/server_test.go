@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iss/internal/models"
+	"iss/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	rs := service.NewResolutionService(service.NewIssueService(nil), service.NewAgentService(nil), service.GetAssignmentStrategy(service.FreeAgentFirst), nil)
+	return NewServer(rs)
+}
+
+// do sends the request with the body encoded as JSON, a string body is sent as it is
+func do(t *testing.T, s *Server, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload string
+	switch b := body.(type) {
+	case nil:
+	case string:
+		payload = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		payload = string(data)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(payload)))
+	return w
+}
+
+func mustDo(t *testing.T, s *Server, method, path string, body any, want int, v any) {
+	t.Helper()
+	w := do(t, s, method, path, body)
+	if w.Code != want {
+		t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body.String(), want)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: decoding %s: %v", method, path, w.Body.String(), err)
+		}
+	}
+}
+
+func createIssue(t *testing.T, s *Server, txnId string) string {
+	t.Helper()
+	var created idResponse
+	mustDo(t, s, "POST", "/issues", createIssueRequest{
+		TxnId: txnId, Type: models.Payment, Priority: models.P2, Subject: "Payment Failed", Description: "money debited", Email: "customer@test.com",
+	}, http.StatusCreated, &created)
+	return created.Id
+}
+
+func TestIssueLifecycleRoutes(t *testing.T) {
+	s := newTestServer(t)
+	var agent idResponse
+	mustDo(t, s, "POST", "/agents", addAgentRequest{
+		Name: "Asha", Email: "asha@test.com", Expertise: map[models.IssueType]models.Skill{models.Payment: models.DefaultSkill},
+	}, http.StatusCreated, &agent)
+	id := createIssue(t, s, "T1")
+
+	var assigned assignIssueResponse
+	mustDo(t, s, "POST", "/issues/"+id+"/assign", nil, http.StatusOK, &assigned)
+	if assigned.AgentId != agent.Id || assigned.Waitlisted {
+		t.Errorf("assign = %+v, want %s without waitlisting", assigned, agent.Id)
+	}
+	var issue models.Issue
+	mustDo(t, s, "GET", "/issues/"+id, nil, http.StatusOK, &issue)
+	if issue.Id != id || issue.Status != models.Assigned {
+		t.Errorf("GET /issues/%s = %s in %s, want it Assigned", id, issue.Id, issue.Status)
+	}
+
+	mustDo(t, s, "POST", "/issues/"+id+"/comments", addCommentRequest{Author: agent.Id, Body: "looking into the refund"}, http.StatusCreated, nil)
+	mustDo(t, s, "POST", "/issues/"+id+"/resolve", resolveIssueRequest{Resolution: "refunded"}, http.StatusNoContent, nil)
+	var page service.IssuePage
+	mustDo(t, s, "GET", "/issues?status=resolved", nil, http.StatusOK, &page)
+	if len(page.Issues) != 1 || page.Issues[0].Id != id {
+		t.Errorf("GET /issues?status=resolved = %v, want only %s", page.Issues, id)
+	}
+	var found searchResponse
+	mustDo(t, s, "GET", "/search?q=refunds", nil, http.StatusOK, &found)
+	if len(found.Hits) != 1 || found.Hits[0].Issue.Id != id {
+		t.Errorf("GET /search?q=refunds = %v, want %s", found.Hits, id)
+	}
+	var timeline []models.TimelineEntry
+	mustDo(t, s, "GET", "/issues/"+id+"/timeline", nil, http.StatusOK, &timeline)
+	if len(timeline) == 0 {
+		t.Errorf("GET /issues/%s/timeline is empty", id)
+	}
+	var history map[string]service.WorkHistory
+	mustDo(t, s, "GET", "/agents/history", nil, http.StatusOK, &history)
+	if resolved := history[agent.Id].ResolvedIssues; len(resolved) != 1 || resolved[0] != id {
+		t.Errorf("work history of %s = %v, want %s resolved", agent.Id, resolved, id)
+	}
+
+	var reopened assignIssueResponse
+	mustDo(t, s, "POST", "/issues/"+id+"/reopen", reopenIssueRequest{Reason: "still debited"}, http.StatusOK, &reopened)
+	if reopened.AgentId != agent.Id {
+		t.Errorf("reopen went to %s, want the resolving agent %s", reopened.AgentId, agent.Id)
+	}
+}
+
+func TestCustomerRoutes(t *testing.T) {
+	s := newTestServer(t)
+	id := createIssue(t, s, "T1")
+	var customer models.Customer
+	mustDo(t, s, "GET", "/customers?email=Customer@Test.com", nil, http.StatusOK, &customer)
+	mustDo(t, s, "PUT", "/customers/"+customer.Id+"/notifications", optOutRequest{OptOut: true}, http.StatusNoContent, nil)
+	mustDo(t, s, "GET", "/customers/"+customer.Id, nil, http.StatusOK, &customer)
+	if !customer.OptedOut {
+		t.Errorf("customer %s has not opted out", customer.Id)
+	}
+	var history service.CustomerHistory
+	mustDo(t, s, "GET", "/customers/"+customer.Id+"/issues", nil, http.StatusOK, &history)
+	if history.OpenIssues != 1 || len(history.Issues.Issues) != 1 || history.Issues.Issues[0].Id != id {
+		t.Errorf("customer issues = %d open, %v, want only %s", history.OpenIssues, history.Issues.Issues, id)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	s := newTestServer(t)
+	unassigned := createIssue(t, s, "T1")
+	tests := []struct {
+		method, path string
+		body         any
+		want         int
+	}{
+		{"GET", "/issues/I404", nil, http.StatusNotFound},
+		{"POST", "/issues/I404/assign", nil, http.StatusNotFound},
+		{"GET", "/customers/C404", nil, http.StatusNotFound},
+		{"POST", "/issues", "{not json", http.StatusBadRequest},
+		{"POST", "/issues", `{"txn_id":"T2","severity":"high"}`, http.StatusBadRequest},
+		{"POST", "/issues", createIssueRequest{TxnId: "T2", Type: models.Payment}, http.StatusBadRequest},
+		{"GET", "/issues?colour=red", nil, http.StatusBadRequest},
+		{"GET", "/unassigned/crypto", nil, http.StatusBadRequest},
+		{"GET", "/customers", nil, http.StatusBadRequest},
+		{"PATCH", "/issues/" + unassigned, updateIssueRequest{Status: models.InProgress, Resolution: "checking"}, http.StatusConflict},
+		{"POST", "/issues/" + unassigned + "/assign", nil, http.StatusConflict},
+		{"POST", "/issues/" + unassigned + "/resolve", resolveIssueRequest{Resolution: "refunded"}, http.StatusConflict},
+		{"POST", "/issues/" + unassigned + "/reopen", reopenIssueRequest{Reason: "again"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		w := do(t, s, tt.method, tt.path, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body.String(), tt.want)
+			continue
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Errorf("%s %s answered %s, want an error message", tt.method, tt.path, w.Body.String())
+		}
+	}
+}
+
+func TestStatusFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrIssueNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: I1", service.ErrIssueNotFound), http.StatusNotFound},
+		{service.ErrAgentNotFound, http.StatusNotFound},
+		{service.ErrCustomerNotFound, http.StatusNotFound},
+		{service.ErrWebhookNotFound, http.StatusNotFound},
+		{service.ErrDeadLetterNotFound, http.StatusNotFound},
+		{models.ErrAgentBusy, http.StatusConflict},
+		{service.ErrIssueNotAssigned, http.StatusConflict},
+		{service.ErrIssueAlreadyAssigned, http.StatusConflict},
+		{service.ErrIssueNotActive, http.StatusConflict},
+		{service.ErrNoAgentAvailable, http.StatusConflict},
+		{service.ErrAgentDeactivated, http.StatusConflict},
+		{service.ErrAgentUnavailable, http.StatusConflict},
+		{service.ErrDuplicateTransaction, http.StatusConflict},
+		{service.ErrOpenIssueLimit, http.StatusConflict},
+		{&models.InvalidTransitionError{IssueId: "I1", From: models.Created, To: models.Resolved}, http.StatusConflict},
+		{models.ErrInvalidIssue, http.StatusBadRequest},
+		{models.ErrInvalidAgent, http.StatusBadRequest},
+		{models.ErrInvalidCustomer, http.StatusBadRequest},
+		{models.ErrInvalidWebhook, http.StatusBadRequest},
+		{service.ErrStatusManaged, http.StatusBadRequest},
+		{service.ErrInvalidQuery, http.StatusBadRequest},
+		{errBadRequest, http.StatusBadRequest},
+		{service.ErrDeliveryFailed, http.StatusBadGateway},
+		{service.ErrEventLogFailed, http.StatusServiceUnavailable},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := statusFor(tt.err); got != tt.want {
+			t.Errorf("statusFor(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// run with -race, issues are read while comments are added to them
+func TestReadsDoNotRaceWrites(t *testing.T) {
+	s := newTestServer(t)
+	id := createIssue(t, s, "T1")
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			do(t, s, "POST", "/issues/"+id+"/comments", addCommentRequest{Kind: models.CustomerReply, Author: "customer@test.com", Body: "any news?"})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			do(t, s, "GET", "/issues/"+id, nil)
+			do(t, s, "GET", "/issues", nil)
+			do(t, s, "GET", "/customers?email=customer@test.com", nil)
+		}
+	}()
+	wg.Wait()
+}
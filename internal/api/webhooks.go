@@ -0,0 +1,117 @@
+package api
+
+import (
+	"iss/internal/models"
+	"iss/internal/service"
+	"net/http"
+)
+
+type ServerOption func(s *Server)
+
+// WithWebhooks serves the webhook subscriptions and dead letters of the service under /webhooks and /dead-letters
+func WithWebhooks(ws *service.WebhookService) ServerOption {
+	return func(s *Server) {
+		s.webhooks = ws
+	}
+}
+
+func (s *Server) routeWebhooks() {
+	s.mux.HandleFunc("POST /webhooks", s.registerWebhook)
+	s.mux.HandleFunc("GET /webhooks", s.listWebhooks)
+	s.mux.HandleFunc("GET /webhooks/{id}", s.getWebhook)
+	s.mux.HandleFunc("DELETE /webhooks/{id}", s.deleteWebhook)
+	s.mux.HandleFunc("GET /dead-letters", s.listDeadLetters)
+	s.mux.HandleFunc("POST /dead-letters/{id}/replay", s.replayDeadLetter)
+	s.mux.HandleFunc("DELETE /dead-letters/{id}", s.discardDeadLetter)
+}
+
+type webhookRequest struct {
+	URL        string             `json:"url"`
+	Events     []string           `json:"events"`
+	IssueTypes []models.IssueType `json:"issue_types"`
+	Secret     string             `json:"secret"`
+}
+
+// webhookResponse leaves the secret out, it is only returned once by registerWebhook
+type webhookResponse struct {
+	Id         string             `json:"id"`
+	URL        string             `json:"url"`
+	Events     []string           `json:"events,omitempty"`
+	IssueTypes []models.IssueType `json:"issue_types,omitempty"`
+	Secret     string             `json:"secret,omitempty"`
+	CreatedAt  int64              `json:"created_at"`
+}
+
+func newWebhookResponse(webhook *models.Webhook) webhookResponse {
+	return webhookResponse{
+		Id:         webhook.Id,
+		URL:        webhook.URL,
+		Events:     webhook.Events,
+		IssueTypes: webhook.IssueTypes,
+		CreatedAt:  webhook.CreatedAt,
+	}
+}
+
+func (s *Server) registerWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	webhook, err := s.webhooks.RegisterWebhook(req.URL, req.Events, req.IssueTypes, req.Secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := newWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks := s.webhooks.ListWebhooks()
+	resp := make([]webhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, newWebhookResponse(webhook))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := s.webhooks.GetWebhook(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newWebhookResponse(webhook))
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.DeleteWebhook(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDeadLetters returns the failed deliveries of the webhook in the webhook_id query parameter, of every
+// webhook when it is missing
+func (s *Server) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.webhooks.DeadLetters(r.URL.Query().Get("webhook_id")))
+}
+
+func (s *Server) replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.ReplayDeadLetter(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) discardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.DiscardDeadLetter(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Handler reacts to an event published on a Bus. A synchronous handler runs in the goroutine of Publish or Post, for
+// the resolution service that is under its lock before the lifecycle operation returns, so it must be quick and must
+// not call back into the service.
+type Handler func(e Event)
+
+// OverflowPolicy decides what Post does once the outbox is full
+type OverflowPolicy int
+
+const (
+	// BlockWhenFull makes Post wait for room in the outbox, pushing back on the publisher
+	BlockWhenFull OverflowPolicy = iota
+	// DropWhenFull makes Post discard the event for the asynchronous subscribers, counted by Dropped
+	DropWhenFull
+)
+
+// DefaultOutboxSize is the number of posted events NewBus holds for its asynchronous subscribers
+const DefaultOutboxSize = 1024
+
+// Bus fans the events committed by the resolution workflow out to in-process subscribers. Synchronous
+// subscribers run inside Publish and Post, asynchronous ones on their own workers. Either way every subscriber
+// sees the events of an issue in the order they were published.
+type Bus struct {
+	subs []*Subscription
+	mu   sync.RWMutex
+
+	// outbox holds the posted events the dispatcher has yet to hand to the asynchronous subscribers
+	outbox     chan Event
+	overflow   OverflowPolicy
+	dropped    atomic.Uint64
+	closed     bool
+	closeMu    sync.RWMutex
+	dispatched chan struct{}
+}
+
+// NewBus returns a bus whose outbox holds DefaultOutboxSize events and makes Post wait when it is full
+func NewBus() *Bus {
+	return NewBoundedBus(DefaultOutboxSize, BlockWhenFull)
+}
+
+// NewBoundedBus returns a bus whose outbox holds size posted events, what Post does once it is full is up to the
+// overflow policy. The bus dispatches from its own goroutine until Close.
+func NewBoundedBus(size int, overflow OverflowPolicy) *Bus {
+	b := &Bus{outbox: make(chan Event, max(size, 0)), overflow: overflow, dispatched: make(chan struct{})}
+	go b.dispatch()
+	return b
+}
+
+// Subscription is a handler registered on a Bus for some event types
+type Subscription struct {
+	bus     *Bus
+	handler Handler
+	types   map[EventType]bool // empty means every type
+	workers []chan Event       // nil for synchronous subscribers
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// Subscribe calls the handler from Publish and Post for every event of the given types, of any type when none are given
+func (b *Bus) Subscribe(handler Handler, types ...EventType) *Subscription {
+	s := newSubscription(b, handler, types)
+	b.add(s)
+	return s
+}
+
+// SubscribeAsync hands the events of the given types to the handler on the given number of workers, each with a
+// buffer of that many events. An issue always goes to the same worker so its events are handled in order, events
+// of different issues may be handled concurrently. Publish blocks while the chosen worker's buffer is full so that no
+// event is missed, publishers that cannot afford to wait on a subscriber Post instead.
+func (b *Bus) SubscribeAsync(handler Handler, workers, buffer int, types ...EventType) *Subscription {
+	s := newSubscription(b, handler, types)
+	s.workers = make([]chan Event, max(workers, 1))
+	for i := range s.workers {
+		queue := make(chan Event, max(buffer, 0))
+		s.workers[i] = queue
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for e := range queue {
+				s.handle(e)
+			}
+		}()
+	}
+	b.add(s)
+	return s
+}
+
+func newSubscription(b *Bus, handler Handler, types []EventType) *Subscription {
+	s := &Subscription{bus: b, handler: handler, types: make(map[EventType]bool)}
+	for _, t := range types {
+		s.types[t] = true
+	}
+	return s
+}
+
+func (b *Bus) add(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, s)
+}
+
+// Publish delivers the event to every subscriber of its type, waiting while the buffer of an asynchronous
+// subscriber's worker is full so that no event is missed
+func (b *Bus) Publish(e Event) {
+	b.publish(e, true, true)
+}
+
+func (b *Bus) publish(e Event, sync, async bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if len(s.types) > 0 && !s.types[e.Type] {
+			continue
+		}
+		if s.workers == nil {
+			if sync {
+				s.handle(e)
+			}
+			continue
+		}
+		if async {
+			s.workers[shard(e, len(s.workers))] <- e
+		}
+	}
+}
+
+// Post runs the synchronous subscribers right away and queues the event in the bounded outbox for the asynchronous
+// ones, which a dispatcher goroutine hands the posted events in order. Only the dispatcher waits on full subscriber
+// buffers, Post waits or drops the event once the outbox is full, as the overflow policy says. Events posted after
+// Close are dropped.
+func (b *Bus) Post(e Event) {
+	b.publish(e, true, false)
+
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+	if b.closed {
+		b.drop(e)
+		return
+	}
+	if b.overflow == BlockWhenFull {
+		b.outbox <- e
+		return
+	}
+	select {
+	case b.outbox <- e:
+	default:
+		b.drop(e)
+	}
+}
+
+func (b *Bus) drop(e Event) {
+	b.dropped.Add(1)
+	fmt.Printf("dropped %s event %d, the bus outbox is full or closed \n", e.Type, e.Seq)
+}
+
+// Dropped returns the number of posted events the asynchronous subscribers never got
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// dispatch hands the outbox to the asynchronous subscribers until Close
+func (b *Bus) dispatch() {
+	defer close(b.dispatched)
+	for e := range b.outbox {
+		b.publish(e, false, true)
+	}
+}
+
+// Close hands what is left in the outbox to the asynchronous subscribers and unsubscribes everyone, waiting for
+// them to handle the events they have buffered
+func (b *Bus) Close() {
+	b.closeMu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.outbox)
+	}
+	b.closeMu.Unlock()
+	<-b.dispatched
+
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+// Unsubscribe stops delivering events to the subscription, an asynchronous one first handles what it has buffered
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		b := s.bus
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(other *Subscription) bool { return other == s })
+		b.mu.Unlock()
+		for _, queue := range s.workers {
+			close(queue)
+		}
+		s.wg.Wait()
+	})
+}
+
+// handle keeps a panicking subscriber from taking the publisher down with it
+func (s *Subscription) handle(e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("subscriber panicked handling %s event %d: %v \n", e.Type, e.Seq, r)
+		}
+	}()
+	s.handler(e)
+}
+
+// shard picks the worker of the event's issue, events without one are spread by agent and then customer
+func shard(e Event, workers int) int {
+	key := e.IssueId
+	if key == "" {
+		key = e.AgentId
+	}
+	if key == "" {
+		key = e.CustomerId
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(workers))
+}
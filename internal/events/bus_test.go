@@ -0,0 +1,168 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostDoesNotWaitForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []uint64
+	bus.SubscribeAsync(func(e Event) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.Seq)
+	}, 1, 1)
+
+	posted := make(chan struct{})
+	go func() {
+		defer close(posted)
+		for seq := uint64(1); seq <= 100; seq++ {
+			bus.Post(Event{Seq: seq, Type: IssueCommented, IssueId: "I1"})
+		}
+	}()
+	select {
+	case <-posted:
+	case <-time.After(time.Second):
+		t.Fatal("Post blocked on a subscriber whose buffer is full")
+	}
+
+	close(release)
+	bus.Close()
+	if len(handled) != 100 {
+		t.Fatalf("subscriber handled %d events, want 100", len(handled))
+	}
+	for i, seq := range handled {
+		if seq != uint64(i+1) {
+			t.Fatalf("event %d handled as %d, posted events must keep their order", i+1, seq)
+		}
+	}
+}
+
+func TestAsyncSubscriberKeepsIssueOrder(t *testing.T) {
+	bus := NewBus()
+	var mu sync.Mutex
+	last := make(map[string]uint64)
+	outOfOrder := 0
+	bus.SubscribeAsync(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Seq < last[e.IssueId] {
+			outOfOrder++
+		}
+		last[e.IssueId] = e.Seq
+	}, 4, 8)
+
+	for seq := uint64(1); seq <= 1000; seq++ {
+		bus.Publish(Event{Seq: seq, Type: IssueCommented, IssueId: fmt.Sprintf("I%d", seq%10)})
+	}
+	bus.Close()
+	if outOfOrder > 0 {
+		t.Errorf("%d events were handled before an earlier event of their issue", outOfOrder)
+	}
+	if len(last) != 10 {
+		t.Errorf("events of %d issues handled, want 10", len(last))
+	}
+}
+
+func TestSubscribeFiltersTypes(t *testing.T) {
+	bus := NewBus()
+	var got []EventType
+	bus.Subscribe(func(e Event) { got = append(got, e.Type) }, IssueResolved)
+	bus.Publish(Event{Type: IssueCreated})
+	bus.Publish(Event{Type: IssueResolved})
+	bus.Close()
+	if len(got) != 1 || got[0] != IssueResolved {
+		t.Errorf("subscriber to %s got %v", IssueResolved, got)
+	}
+}
+
+// blockedSubscriber subscribes a single worker with a buffer of one that handles nothing until release is closed
+func blockedSubscriber(bus *Bus, release chan struct{}) *[]uint64 {
+	var mu sync.Mutex
+	handled := make([]uint64, 0)
+	bus.SubscribeAsync(func(e Event) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.Seq)
+	}, 1, 1)
+	return &handled
+}
+
+func TestPostBlocksOnceOutboxIsFull(t *testing.T) {
+	bus := NewBoundedBus(2, BlockWhenFull)
+	release := make(chan struct{})
+	handled := blockedSubscriber(bus, release)
+
+	// the worker, its buffer, the dispatcher and the outbox hold five events between them, the sixth has to wait
+	posted := make(chan uint64, 10)
+	go func() {
+		for seq := uint64(1); seq <= 10; seq++ {
+			bus.Post(Event{Seq: seq, Type: IssueCommented, IssueId: "I1"})
+			posted <- seq
+		}
+		close(posted)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if n := len(posted); n > 5 {
+		t.Fatalf("%d posts returned while the subscriber was stuck, want the outbox to push back after 5", n)
+	}
+
+	close(release)
+	for range posted {
+	}
+	bus.Close()
+	if len(*handled) != 10 || bus.Dropped() != 0 {
+		t.Errorf("handled %d events and dropped %d, want all 10 handled", len(*handled), bus.Dropped())
+	}
+}
+
+func TestPostDropsOnceOutboxIsFull(t *testing.T) {
+	bus := NewBoundedBus(2, DropWhenFull)
+	release := make(chan struct{})
+	handled := blockedSubscriber(bus, release)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seq := uint64(1); seq <= 100; seq++ {
+			bus.Post(Event{Seq: seq, Type: IssueCommented, IssueId: "I1"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Post waited although the bus drops what does not fit")
+	}
+
+	close(release)
+	bus.Close()
+	if bus.Dropped() == 0 || uint64(len(*handled))+bus.Dropped() != 100 {
+		t.Errorf("handled %d events and dropped %d, want every event either handled or counted as dropped", len(*handled), bus.Dropped())
+	}
+	for i := 1; i < len(*handled); i++ {
+		if (*handled)[i] <= (*handled)[i-1] {
+			t.Fatalf("events handled as %v, the ones kept must keep their order", *handled)
+		}
+	}
+}
+
+func TestPostRunsSynchronousSubscribersBeforeReturning(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+	var handled []uint64
+	bus.Subscribe(func(e Event) { handled = append(handled, e.Seq) })
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		bus.Post(Event{Seq: seq, Type: IssueCommented, IssueId: "I1"})
+		if len(handled) != int(seq) {
+			t.Fatalf("Post of event %d returned before the synchronous subscriber ran, handled %v", seq, handled)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"fmt"
+	"iss/internal/models"
+	"strings"
+)
+
+type EventType string
+
+const (
+	AgentAdded           EventType = "AgentAdded"
+	IssueCreated         EventType = "IssueCreated"
+	IssueAssigned        EventType = "IssueAssigned"
+	IssueWaitlisted      EventType = "IssueWaitlisted"
+	IssueParked          EventType = "IssueParked"
+	IssueStatusChanged   EventType = "IssueStatusChanged"
+	IssueResolved        EventType = "IssueResolved"
+	IssueReopened        EventType = "IssueReopened"
+	IssueEscalated       EventType = "IssueEscalated"
+	PendingIssuePromoted EventType = "PendingIssuePromoted"
+	AgentPresenceChanged EventType = "AgentPresenceChanged"
+	AgentShiftsChanged   EventType = "AgentShiftsChanged"
+	// PendingIssueMoved takes an issue out of an unavailable agent's queue, AgentId is its new agent
+	// and is empty when the issue was parked in the unassigned pool
+	PendingIssueMoved EventType = "PendingIssueMoved"
+	AgentDeactivated  EventType = "AgentDeactivated"
+	AgentActivated    EventType = "AgentActivated"
+	AgentRemoved      EventType = "AgentRemoved"
+	// IssueHandedOver takes an active issue away from a deactivated agent, AgentId is its new agent
+	// and is empty when the issue was parked in the unassigned pool
+	IssueHandedOver EventType = "IssueHandedOver"
+	// IssueTransferred moves an issue from whoever holds it to AgentId on a supervisor's request
+	IssueTransferred EventType = "IssueTransferred"
+	IssueCommented   EventType = "IssueCommented"
+	// IssueMerged adds another complaint about the transaction of IssueId to its timeline, Email, Subject
+	// and Description are the complaint's
+	IssueMerged     EventType = "IssueMerged"
+	CustomerAdded   EventType = "CustomerAdded"
+	CustomerUpdated EventType = "CustomerUpdated"
+	// IssueCancelled withdraws an open issue from whoever holds it, AgentId is the agent that was working on it or
+	// had it queued
+	IssueCancelled EventType = "IssueCancelled"
+	// CustomerOptOutChanged records whether the customer wants to be emailed about their issues
+	CustomerOptOutChanged EventType = "CustomerOptOutChanged"
+)
+
+var eventTypes = []EventType{
+	AgentAdded, IssueCreated, IssueAssigned, IssueWaitlisted, IssueParked, IssueStatusChanged, IssueResolved,
+	IssueReopened, IssueEscalated, PendingIssuePromoted, AgentPresenceChanged, AgentShiftsChanged, PendingIssueMoved,
+	AgentDeactivated, AgentActivated, AgentRemoved, IssueHandedOver, IssueTransferred, IssueCommented, IssueMerged,
+	CustomerAdded, CustomerUpdated, CustomerOptOutChanged, IssueCancelled,
+}
+
+func ParseEventType(s string) (EventType, error) {
+	for _, t := range eventTypes {
+		if strings.EqualFold(string(t), s) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type %q", s)
+}
+
+// Event records a single state transition of the resolution workflow.
+// Only the fields relevant to the event type are populated.
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Type      EventType `json:"type"`
+	Timestamp int64     `json:"timestamp"`
+	IssueId   string    `json:"issue_id,omitempty"`
+	AgentId   string    `json:"agent_id,omitempty"`
+	Author    string    `json:"author,omitempty"` // who caused the transition, recorded in the issue's timeline
+
+	// IssueCreated, IssueMerged
+	TxnId       string           `json:"txn_id,omitempty"`
+	IssueType   models.IssueType `json:"issue_type,omitempty"`
+	Priority    models.Priority  `json:"priority,omitempty"`
+	Subject     string           `json:"subject,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Email       string           `json:"email,omitempty"`
+	DuplicateOf string           `json:"duplicate_of,omitempty"` // the open issue of the transaction it was linked to
+	CustomerId  string           `json:"customer_id,omitempty"`  // also set on CustomerAdded and CustomerUpdated
+
+	// IssueStatusChanged, IssueResolved
+	Status     models.IssueStatus `json:"status,omitempty"`
+	Resolution string             `json:"resolution,omitempty"`
+
+	// IssueReopened, IssueEscalated, IssueTransferred, IssueHandedOver, PendingIssueMoved
+	Reason string `json:"reason,omitempty"`
+
+	// AgentAdded
+	Name      string                            `json:"name,omitempty"`
+	Expertise map[models.IssueType]models.Skill `json:"expertise,omitempty"`
+	Capacity  int                               `json:"capacity,omitempty"` // 0 means models.DefaultCapacity
+
+	// IssueCommented
+	Kind    models.EntryKind `json:"kind,omitempty"`
+	Comment string           `json:"comment,omitempty"`
+
+	// CustomerAdded, CustomerUpdated
+	Emails         []string            `json:"emails,omitempty"`
+	Phone          string              `json:"phone,omitempty"`
+	Tier           models.CustomerTier `json:"tier,omitempty"`
+	Locale         string              `json:"locale,omitempty"`
+	OpenIssueLimit int                 `json:"open_issue_limit,omitempty"`
+
+	// CustomerOptOutChanged
+	OptOut bool `json:"opt_out,omitempty"`
+
+	// IssueAssigned, IssueWaitlisted, IssueParked, PendingIssueMoved, IssueHandedOver: the issue was routed for a VIP
+	// customer and goes ahead of the issues that are not expedited
+	Expedite bool `json:"expedite,omitempty"`
+
+	// AgentPresenceChanged, AgentShiftsChanged
+	Presence models.Presence `json:"presence,omitempty"`
+	Shifts   []models.Shift  `json:"shifts,omitempty"`
+}
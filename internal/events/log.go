@@ -0,0 +1,138 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Log is a durable append-only sequence of events
+type Log interface {
+	// Append assigns the next sequence number to the event and returns once it is durable
+	Append(e *Event) error
+	// Replay calls fn for every stored event in the order they were appended
+	Replay(fn func(e Event) error) error
+	Close() error
+}
+
+// FileLog stores one JSON encoded event per line and fsyncs after every append
+type FileLog struct {
+	path string
+	file *os.File
+	seq  uint64
+	mu   sync.Mutex
+}
+
+// OpenFileLog opens the log at path, creating it if needed. A partially written final line,
+// which is what a crash in the middle of Append leaves behind, is truncated away.
+func OpenFileLog(path string) (*FileLog, error) {
+	l := &FileLog{path: path}
+	size, err := l.scan(func(e Event) error {
+		l.seq = e.Seq
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while opening event log %w", err)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error occurred while truncating event log %w", err)
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error occurred while opening event log %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+func (l *FileLog) Append(e *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seq := l.seq + 1
+	e.Seq = seq
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error occurred while encoding event %w", err)
+	}
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error occurred while appending event %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		l.truncate(offset)
+		return fmt.Errorf("error occurred while appending event %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		l.truncate(offset)
+		return fmt.Errorf("error occurred while syncing event log %w", err)
+	}
+	l.seq = seq
+	return nil
+}
+
+// truncate drops what a failed append may have written, so that the event it was refused for is not replayed.
+// Should this fail as well, the event may still be replayed, it was checked before it was appended.
+func (l *FileLog) truncate(offset int64) {
+	if err := l.file.Truncate(offset); err == nil {
+		l.file.Seek(offset, io.SeekStart)
+	}
+}
+
+func (l *FileLog) Replay(fn func(e Event) error) error {
+	_, err := l.scan(fn)
+	return err
+}
+
+// scan calls fn for every complete event and returns the size of the log up to the last complete event
+func (l *FileLog) scan(fn func(e Event) error) (int64, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error occurred while opening event log %w", err)
+	}
+	defer file.Close()
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				fmt.Printf("ignoring torn event at the end of %s \n", l.path)
+			}
+			return size, nil
+		}
+		if err != nil {
+			return size, fmt.Errorf("error occurred while reading event log %w", err)
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return size, fmt.Errorf("error occurred while decoding event at offset %d %w", size, err)
+		}
+		if err := fn(e); err != nil {
+			return size, fmt.Errorf("error occurred while replaying event %d %w", e.Seq, err)
+		}
+		size += int64(len(line))
+	}
+}
+
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -1,28 +1,48 @@
 package models
 
 import (
-	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
+// DefaultCapacity is the number of issues an agent works on at the same time when no capacity is given
+const DefaultCapacity = 1
+
 type Agent struct {
-	Id             string             `json:"id"`
-	Name           string             `json:"name"`
-	Email          string             `json:"email"`
-	Expertise      map[IssueType]bool `json:"expertise"`
-	AssignedIssue  *Issue
-	PendingIssues  []*Issue          // considering it as a list to assume the issues would be picked up in FIFO Order
-	ResolvedIssues map[string]*Issue // stores the resolved issues by their ID
-	HeapIndex      int
-	CreatedAt      int64 `json:"created_at"`
+	Id             string              `json:"id"`
+	Name           string              `json:"name"`
+	Email          string              `json:"email"`
+	Expertise      map[IssueType]Skill `json:"expertise"`
+	Capacity       int                 `json:"capacity"` // maximum number of issues worked on concurrently
+	Presence       Presence            `json:"presence"`
+	Shifts         []Shift             `json:"shifts,omitempty"` // when set, the agent is taken Online and Offline by the schedule
+	ActiveIssues   map[string]*Issue   // issues being worked on, by their ID
+	PendingIssues  []*Issue            // kept ordered by priority and then age, the head is picked up next
+	ResolvedIssues map[string]*Issue   // stores the resolved issues by their ID
+	ReopenedIssues map[string]int      // number of times each issue resolved by the agent was reopened
+	HeapIndex      int                 // position in the busy agent heap, -1 when the agent is not in it
+	CreatedAt      int64               `json:"created_at"`
+	DeactivatedAt  int64               `json:"deactivated_at,omitempty"` // 0 while the agent is active
+	Removed        bool                `json:"removed,omitempty"`        // removed agents are only kept for their history
 	mu             sync.RWMutex
 }
 
-func NewAgent(id, name, email string, expertise map[IssueType]bool) (*Agent, error) {
-	if name == "" || email == "" || len(expertise) == 0 {
-		return nil, fmt.Errorf("invalid agent data")
+// NewAgent builds an agent working on up to capacity issues at once, a capacity of 0 means DefaultCapacity
+func NewAgent(id, name, email string, expertise map[IssueType]Skill, capacity int) (*Agent, error) {
+	if name == "" || email == "" || len(expertise) == 0 || capacity < 0 {
+		return nil, ErrInvalidAgent
+	}
+	if capacity == 0 {
+		capacity = DefaultCapacity
+	}
+	for _, skill := range expertise {
+		if skill.Level <= NoProficiency || skill.Level > Specialist || skill.MaxConcurrent < 0 {
+			return nil, ErrInvalidAgent
+		}
 	}
 
 	return &Agent{
@@ -30,25 +50,153 @@ func NewAgent(id, name, email string, expertise map[IssueType]bool) (*Agent, err
 		Name:           name,
 		Email:          email,
 		Expertise:      expertise,
+		Capacity:       capacity,
+		ActiveIssues:   make(map[string]*Issue),
 		PendingIssues:  []*Issue{},
 		ResolvedIssues: make(map[string]*Issue),
+		ReopenedIssues: make(map[string]int),
+		HeapIndex:      -1,
 		CreatedAt:      time.Now().Unix(),
 	}, nil
 }
 
+// IsAvailable reports whether the agent is below capacity and can start on another issue right away
 func (a *Agent) IsAvailable() bool {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return a.AssignedIssue == nil
+	return len(a.ActiveIssues) < a.Capacity
+}
+
+func (a *Agent) GetPresence() Presence {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Presence
+}
+
+func (a *Agent) SetPresence(presence Presence) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Presence = presence
+}
+
+// AcceptsWork reports whether new issues may be routed to the agent, it must be active and Online
+func (a *Agent) AcceptsWork() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.DeactivatedAt == 0 && a.Presence.AcceptsWork()
+}
+
+func (a *Agent) IsDeactivated() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.DeactivatedAt != 0
+}
+
+func (a *Agent) IsRemoved() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Removed
+}
+
+func (a *Agent) Deactivate(at int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.DeactivatedAt = at
+}
+
+func (a *Agent) Activate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.DeactivatedAt = 0
+}
+
+func (a *Agent) Remove() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Removed = true
+}
+
+func (a *Agent) GetShifts() []Shift {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Shifts
+}
+
+func (a *Agent) SetShifts(shifts []Shift) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Shifts = shifts
+}
+
+// OnShift reports whether any of the agent's shifts covers the instant, an agent without shifts is never on shift
+func (a *Agent) OnShift(t time.Time) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, shift := range a.Shifts {
+		if shift.Covers(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns a copy of the agent taken under its lock, with its own maps and queue. The issues are shared,
+// take their own snapshots to read more than their IDs.
+func (a *Agent) Snapshot() *Agent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return &Agent{
+		Id:             a.Id,
+		Name:           a.Name,
+		Email:          a.Email,
+		Expertise:      maps.Clone(a.Expertise),
+		Capacity:       a.Capacity,
+		Presence:       a.Presence,
+		Shifts:         slices.Clone(a.Shifts),
+		ActiveIssues:   maps.Clone(a.ActiveIssues),
+		PendingIssues:  slices.Clone(a.PendingIssues),
+		ResolvedIssues: maps.Clone(a.ResolvedIssues),
+		ReopenedIssues: maps.Clone(a.ReopenedIssues),
+		HeapIndex:      a.HeapIndex,
+		CreatedAt:      a.CreatedAt,
+		DeactivatedAt:  a.DeactivatedAt,
+		Removed:        a.Removed,
+	}
 }
 
-func (a *Agent) GetAssignedIssue() *Issue {
+func (a *Agent) GetCapacity() int {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return a.AssignedIssue
+	return a.Capacity
 }
 
-func (a *Agent) GetExpertise() map[IssueType]bool {
+// GetActiveIssues returns the issues being worked on ordered by ID
+func (a *Agent) GetActiveIssues() []*Issue {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	issues := make([]*Issue, 0, len(a.ActiveIssues))
+	for _, issue := range a.ActiveIssues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Id < issues[j].Id })
+	return issues
+}
+
+func (a *Agent) HasActiveIssue(issueId string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.ActiveIssues[issueId]
+	return ok
+}
+
+// Utilization is the number of active and pending issues relative to the capacity, above 1 once issues queue up
+func (a *Agent) Utilization() float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return float64(len(a.ActiveIssues)+len(a.PendingIssues)) / float64(a.Capacity)
+}
+
+func (a *Agent) GetExpertise() map[IssueType]Skill {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	return a.Expertise
@@ -66,42 +214,136 @@ func (a *Agent) GetResolvedIssues() map[string]*Issue {
 	return a.ResolvedIssues
 }
 
+func (a *Agent) GetReopenedIssues() map[string]int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.ReopenedIssues
+}
+
 func (a *Agent) HasExpertise(it IssueType) bool {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return a.Expertise[it]
+	return a.Expertise[it].Level > NoProficiency
+}
+
+func (a *Agent) ProficiencyIn(it IssueType) Proficiency {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Expertise[it].Level
+}
+
+// HasCapacityFor reports whether the agent may take on one more issue of the type without exceeding
+// the MaxConcurrent of its skill
+func (a *Agent) HasCapacityFor(it IssueType) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	skill, ok := a.Expertise[it]
+	if !ok || skill.MaxConcurrent == 0 {
+		return true
+	}
+	held := 0
+	for _, issue := range a.ActiveIssues {
+		if issue.Type == it {
+			held++
+		}
+	}
+	for _, issue := range a.PendingIssues {
+		if issue.Type == it {
+			held++
+		}
+	}
+	return held < skill.MaxConcurrent
+}
+
+// PeekPendingIssue returns the issue that would be picked up next once an active issue is resolved
+func (a *Agent) PeekPendingIssue() *Issue {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.PendingIssues) == 0 {
+		return nil
+	}
+	return a.PendingIssues[0]
 }
 
 func (a *Agent) AddToPendingIssues(issue *Issue) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.PendingIssues = append(a.PendingIssues, issue)
+	// insert behind every issue that is at least as urgent and no younger, which keeps FIFO order within a priority,
+	// expedited issues preempt all the others
+	pos := sort.Search(len(a.PendingIssues), func(i int) bool {
+		return issue.PendingBefore(a.PendingIssues[i])
+	})
+	a.PendingIssues = slices.Insert(a.PendingIssues, pos, issue)
 	fmt.Printf("Issue %s has been added to pending-issues-queue of agent %s \n", issue.Id, a.Id)
 }
 
+func (a *Agent) RemovePendingIssue(issueId string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, issue := range a.PendingIssues {
+		if issue.Id == issueId {
+			a.PendingIssues = slices.Delete(a.PendingIssues, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Agent) AssignIssue(issue *Issue) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.AssignedIssue != nil {
-		return errors.New("agent is already assigned an issue")
+	if len(a.ActiveIssues) >= a.Capacity {
+		return ErrAgentBusy
 	}
-	a.AssignedIssue = issue
+	a.ActiveIssues[issue.Id] = issue
 	return nil
 }
 
-// resolve issue automatically assigns an issue if there are any pending issues
-func (a *Agent) ResolveIssue(resolution string) (*Issue, error) {
+// resolve issue frees the issue's slot and automatically fills it with the most urgent pending issue if there are any
+func (a *Agent) ResolveIssue(issueId, resolution string) (*Issue, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.AssignedIssue != nil {
-		a.ResolvedIssues[a.AssignedIssue.Id] = a.AssignedIssue
-		a.AssignedIssue = nil
-		if len(a.PendingIssues) > 0 {
-			a.AssignedIssue = a.PendingIssues[0]
-			a.PendingIssues = a.PendingIssues[1:]
-		}
-		return a.AssignedIssue, nil
-	} else {
-		return nil, fmt.Errorf("no assigned issue to resolve")
+	issue, ok := a.ActiveIssues[issueId]
+	if !ok {
+		return nil, fmt.Errorf("issue %s is not active with agent %s", issueId, a.Id)
 	}
+	a.ResolvedIssues[issueId] = issue
+	delete(a.ActiveIssues, issueId)
+	if len(a.PendingIssues) == 0 {
+		return nil, nil
+	}
+	next := a.PendingIssues[0]
+	a.PendingIssues = a.PendingIssues[1:]
+	a.ActiveIssues[next.Id] = next
+	return next, nil
+}
+
+// ReleaseIssue takes an active issue away from the agent without resolving it, like ResolveIssue the freed
+// slot is filled with the most urgent pending issue if there are any
+func (a *Agent) ReleaseIssue(issueId string) (*Issue, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.ActiveIssues[issueId]; !ok {
+		return nil, fmt.Errorf("issue %s is not active with agent %s", issueId, a.Id)
+	}
+	delete(a.ActiveIssues, issueId)
+	if len(a.PendingIssues) == 0 {
+		return nil, nil
+	}
+	next := a.PendingIssues[0]
+	a.PendingIssues = a.PendingIssues[1:]
+	a.ActiveIssues[next.Id] = next
+	return next, nil
+}
+
+// ReopenIssue takes a resolved issue out of the agent's history and counts the reopen against the agent
+func (a *Agent) ReopenIssue(issueId string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.ResolvedIssues[issueId]; !ok {
+		return fmt.Errorf("issue %s was not resolved by agent %s", issueId, a.Id)
+	}
+	delete(a.ResolvedIssues, issueId)
+	a.ReopenedIssues[issueId]++
+	return nil
 }
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CustomerTier sets how a customer's issues are treated, tiers are appended so the values stay stable
+type CustomerTier int
+
+const (
+	Standard CustomerTier = iota
+	Premium
+	VIP
+)
+
+var customerTierNames = map[CustomerTier]string{
+	Standard: "Standard",
+	Premium:  "Premium",
+	VIP:      "VIP",
+}
+
+func (t CustomerTier) String() string {
+	if name, ok := customerTierNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func ParseCustomerTier(s string) (CustomerTier, error) {
+	if s == "" {
+		return Standard, nil
+	}
+	for tier, name := range customerTierNames {
+		if strings.EqualFold(name, s) {
+			return tier, nil
+		}
+	}
+	return Standard, fmt.Errorf("unknown customer tier %q", s)
+}
+
+func (t CustomerTier) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *CustomerTier) UnmarshalText(text []byte) error {
+	parsed, err := ParseCustomerTier(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// DefaultLocale is used for customers who did not tell us their language
+const DefaultLocale = "en"
+
+// Customer is the person behind one or more issues, recognised by any of their emails
+type Customer struct {
+	Id             string       `json:"id"`
+	Emails         []string     `json:"emails"` // lowercased, the first one is where we write to
+	Phone          string       `json:"phone,omitempty"`
+	Tier           CustomerTier `json:"tier"`
+	Locale         string       `json:"locale"`
+	OpenIssueLimit int          `json:"open_issue_limit,omitempty"` // how many issues may be open at once, 0 means no limit
+	CreatedAt      int64        `json:"created_at"`
+	LastContactAt  int64        `json:"last_contact_at,omitempty"` // the last time the customer raised an issue or replied
+	OptedOut       bool         `json:"opted_out,omitempty"`       // the customer asked not to be emailed about their issues
+	mu             sync.RWMutex
+}
+
+// NewCustomer builds a customer reachable at the given emails, an empty locale means DefaultLocale
+func NewCustomer(id string, emails []string, phone string, tier CustomerTier, locale string, openIssueLimit int) (*Customer, error) {
+	customer := &Customer{Id: id, CreatedAt: time.Now().Unix()}
+	if err := customer.Update(emails, phone, tier, locale, openIssueLimit); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+// Update replaces the customer's contact details, tier and limit after validating them
+func (c *Customer) Update(emails []string, phone string, tier CustomerTier, locale string, openIssueLimit int) error {
+	if len(emails) == 0 || openIssueLimit < 0 {
+		return ErrInvalidCustomer
+	}
+	if _, ok := customerTierNames[tier]; !ok {
+		return fmt.Errorf("%w: unknown tier %d", ErrInvalidCustomer, tier)
+	}
+	normalized := make([]string, 0, len(emails))
+	for _, email := range emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email == "" {
+			return ErrInvalidCustomer
+		}
+		if !slices.Contains(normalized, email) {
+			normalized = append(normalized, email)
+		}
+	}
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Emails, c.Phone, c.Tier, c.Locale, c.OpenIssueLimit = normalized, phone, tier, locale, openIssueLimit
+	return nil
+}
+
+// Snapshot returns a copy of the customer taken under its lock, safe to encode while the customer keeps changing
+func (c *Customer) Snapshot() *Customer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return &Customer{
+		Id:             c.Id,
+		Emails:         slices.Clone(c.Emails),
+		Phone:          c.Phone,
+		Tier:           c.Tier,
+		Locale:         c.Locale,
+		OpenIssueLimit: c.OpenIssueLimit,
+		CreatedAt:      c.CreatedAt,
+		LastContactAt:  c.LastContactAt,
+		OptedOut:       c.OptedOut,
+	}
+}
+
+func (c *Customer) GetEmails() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.Emails...)
+}
+
+func (c *Customer) GetTier() CustomerTier {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Tier
+}
+
+// GetContact returns the email we write to and the customer's locale
+func (c *Customer) GetContact() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Emails[0], c.Locale
+}
+
+func (c *Customer) IsOptedOut() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.OptedOut
+}
+
+func (c *Customer) SetOptedOut(optedOut bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.OptedOut = optedOut
+}
+
+func (c *Customer) GetOpenIssueLimit() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.OpenIssueLimit
+}
+
+func (c *Customer) GetLastContactAt() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LastContactAt
+}
+
+// Contacted records that the customer got in touch at the given unix time, earlier times are ignored
+func (c *Customer) Contacted(at int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at > c.LastContactAt {
+		c.LastContactAt = at
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "errors"
+
+var (
+	ErrInvalidIssue = errors.New("invalid input")
+	ErrInvalidAgent = errors.New("invalid agent data")
+	ErrAgentBusy    = errors.New("agent is already assigned an issue")
+
+	ErrInvalidCustomer = errors.New("invalid customer data")
+
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
@@ -2,6 +2,8 @@ package models
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -31,50 +33,71 @@ func (it IssueType) String() string {
 	}
 }
 
-type IssueStatus int
+// ParseIssueType accepts the String() form of an issue type, ignoring case and spaces
+func ParseIssueType(s string) (IssueType, error) {
+	switch strings.ToLower(strings.ReplaceAll(s, " ", "")) {
+	case "payment":
+		return Payment, nil
+	case "mutualfund":
+		return MutualFund, nil
+	case "gold":
+		return Gold, nil
+	case "insurance":
+		return Insurance, nil
+	case "unknown":
+		return Unknown, nil
+	default:
+		return Unknown, fmt.Errorf("unknown issue type %q", s)
+	}
+}
 
-const (
-	Created IssueStatus = iota
-	InProgress
-	Resolved
-)
+func (it IssueType) MarshalText() ([]byte, error) {
+	return []byte(it.String()), nil
+}
 
-func (it IssueStatus) String() string {
-	switch it {
-	case Created:
-		return "Created"
-	case InProgress:
-		return "InProgress"
-	case Resolved:
-		return "Resolved"
-	default:
-		return "Unknown"
+func (it *IssueType) UnmarshalText(text []byte) error {
+	parsed, err := ParseIssueType(string(text))
+	if err != nil {
+		return err
 	}
+	*it = parsed
+	return nil
 }
 
 type Issue struct {
-	Id          string      `json:"id"`
-	TxnId       string      `json:"txn_id"`
-	Type        IssueType   `json:"type"`
-	Subject     string      `json:"subject"`
-	Description string      `json:"description"`
-	Email       string      `json:"email"`
-	Status      IssueStatus `json:"status"`
-	Resolution  string      `json:"resolution"`
+	Id          string          `json:"id"`
+	TxnId       string          `json:"txn_id"`
+	Type        IssueType       `json:"type"`
+	Priority    Priority        `json:"priority"`
+	Subject     string          `json:"subject"`
+	Description string          `json:"description"`
+	Email       string          `json:"email"`
+	CustomerId  string          `json:"customer_id,omitempty"`
+	Status      IssueStatus     `json:"status"`
+	Resolution  string          `json:"resolution"`
+	ReopenCount int             `json:"reopen_count"`
+	Timeline    []TimelineEntry `json:"timeline,omitempty"`     // append-only, oldest first
+	DuplicateOf string          `json:"duplicate_of,omitempty"` // the open issue of the same transaction this one was linked to
+	Expedited   bool            `json:"expedited,omitempty"`    // routed for a VIP customer, picked up ahead of issues that are not
 	mu          sync.RWMutex
 	// additional fields to track metadata of the issue
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	CreatedAt       int64 `json:"created_at"`
+	UpdatedAt       int64 `json:"updated_at"`
+	FirstResponseAt int64 `json:"first_response_at,omitempty"`
+	ResolvedAt      int64 `json:"resolved_at,omitempty"`
+	EscalatedAt     int64 `json:"escalated_at,omitempty"`
+	ReopenedAt      int64 `json:"reopened_at,omitempty"` // the last time the issue was reopened
 }
 
-func NewIssue(id, txnId, subject, description, email string, issueType IssueType) (*Issue, error) {
-	if txnId == "" || subject == "" || description == "" || email == "" {
-		return nil, fmt.Errorf("invalid input")
+func NewIssue(id, txnId, subject, description, email string, issueType IssueType, priority Priority) (*Issue, error) {
+	if err := ValidateIssue(txnId, subject, description, email); err != nil {
+		return nil, err
 	}
 	return &Issue{
 		Id:          id,
 		TxnId:       txnId,
 		Type:        issueType,
+		Priority:    priority,
 		Subject:     subject,
 		Description: description,
 		Email:       email,
@@ -83,6 +106,34 @@ func NewIssue(id, txnId, subject, description, email string, issueType IssueType
 	}, nil
 }
 
+// ValidateIssue checks the fields a customer must fill in to raise an issue
+func ValidateIssue(txnId, subject, description, email string) error {
+	if txnId == "" || subject == "" || description == "" || email == "" {
+		return ErrInvalidIssue
+	}
+	return nil
+}
+
+// PendingBefore reports whether the issue should be picked up before other, expedited issues first and then by
+// priority and age
+func (i *Issue) PendingBefore(other *Issue) bool {
+	if i.Expedited != other.Expedited {
+		return i.Expedited
+	}
+	if i.Priority != other.Priority {
+		return i.Priority.MoreUrgentThan(other.Priority)
+	}
+	return i.CreatedAt < other.CreatedAt
+}
+
+// Expedite lets the issue jump ahead of every issue that is not expedited wherever it is queued. It must be called
+// before the issue is queued, queues are not reordered.
+func (i *Issue) Expedite() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Expedited = true
+}
+
 // to get the status of the issue
 func (i *Issue) GetStatus() IssueStatus {
 	i.mu.RLock()
@@ -90,14 +141,149 @@ func (i *Issue) GetStatus() IssueStatus {
 	return i.Status
 }
 
+// UpdateStatus moves the issue along the lifecycle and records the latest resolution note,
+// it returns an *InvalidTransitionError when the transition table does not allow the move
 func (i *Issue) UpdateStatus(status IssueStatus, resolution string) (bool, error) {
+	return i.UpdateStatusAt(status, resolution, time.Now().Unix())
+}
+
+// UpdateStatusAt is UpdateStatus for a transition that happened at the given unix time
+func (i *Issue) UpdateStatusAt(status IssueStatus, resolution string, at int64) (bool, error) {
 	if resolution == "" {
-		return false, fmt.Errorf("resolution cannot be empty")
+		return false, fmt.Errorf("%w: resolution cannot be empty", ErrInvalidIssue)
 	}
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	i.Status = status
-	i.UpdatedAt = time.Now().Unix()
+	if err := i.transition(status, at); err != nil {
+		return false, err
+	}
 	i.Resolution = resolution
 	return true, nil
 }
+
+// Reopen moves a resolved issue back into the workflow, the reason replaces the resolution note. The issue's
+// milestones start over, it needs a first response and a resolution again.
+func (i *Issue) Reopen(reason string) error {
+	return i.ReopenAt(reason, time.Now().Unix())
+}
+
+func (i *Issue) ReopenAt(reason string, at int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i.transition(Reopened, at); err != nil {
+		return err
+	}
+	i.ReopenCount++
+	i.Resolution = reason
+	i.ReopenedAt = at
+	i.FirstResponseAt = 0
+	i.ResolvedAt = 0
+	i.EscalatedAt = 0
+	return nil
+}
+
+func (i *Issue) AppendTimeline(entry TimelineEntry) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Timeline = append(i.Timeline, entry)
+}
+
+func (i *Issue) GetTimeline() []TimelineEntry {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]TimelineEntry{}, i.Timeline...)
+}
+
+func (i *Issue) GetReopenCount() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.ReopenCount
+}
+
+// SetStatus moves the issue along the lifecycle without touching the resolution note
+func (i *Issue) SetStatus(status IssueStatus) error {
+	return i.SetStatusAt(status, time.Now().Unix())
+}
+
+func (i *Issue) SetStatusAt(status IssueStatus, at int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.transition(status, at)
+}
+
+// Escalate moves the issue to Escalated and records when. An issue whose lifecycle does not allow it is left as is,
+// so that EscalatedAt only ever marks an escalation that happened.
+func (i *Issue) Escalate(at int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.transition(Escalated, at) == nil {
+		i.EscalatedAt = at
+	}
+}
+
+// GetTimestamps returns the CreatedAt, FirstResponseAt, ResolvedAt and EscalatedAt unix times of the issue
+func (i *Issue) GetTimestamps() (createdAt, firstResponseAt, resolvedAt, escalatedAt int64) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.CreatedAt, i.FirstResponseAt, i.ResolvedAt, i.EscalatedAt
+}
+
+// SLAStartedAt returns when the SLA clock of the issue started, when it was created or last reopened
+func (i *Issue) SLAStartedAt() int64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return max(i.CreatedAt, i.ReopenedAt)
+}
+
+func (i *Issue) GetUpdatedAt() int64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.UpdatedAt
+}
+
+// Snapshot returns a copy of the issue taken under its lock, safe to encode while the issue keeps changing
+func (i *Issue) Snapshot() *Issue {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return &Issue{
+		Id:              i.Id,
+		TxnId:           i.TxnId,
+		Type:            i.Type,
+		Priority:        i.Priority,
+		Subject:         i.Subject,
+		Description:     i.Description,
+		Email:           i.Email,
+		CustomerId:      i.CustomerId,
+		Status:          i.Status,
+		Resolution:      i.Resolution,
+		ReopenCount:     i.ReopenCount,
+		Timeline:        slices.Clone(i.Timeline),
+		DuplicateOf:     i.DuplicateOf,
+		Expedited:       i.Expedited,
+		CreatedAt:       i.CreatedAt,
+		UpdatedAt:       i.UpdatedAt,
+		FirstResponseAt: i.FirstResponseAt,
+		ResolvedAt:      i.ResolvedAt,
+		EscalatedAt:     i.EscalatedAt,
+		ReopenedAt:      i.ReopenedAt,
+	}
+}
+
+func (i *Issue) transition(status IssueStatus, at int64) error {
+	if !CanTransition(i.Status, status) {
+		return &InvalidTransitionError{IssueId: i.Id, From: i.Status, To: status}
+	}
+	i.Status = status
+	i.UpdatedAt = at
+	switch status {
+	case InProgress, OnHoldCustomer, Resolved:
+		// the first time an agent works on the issue counts as the first response
+		if i.FirstResponseAt == 0 {
+			i.FirstResponseAt = at
+		}
+	}
+	if status == Resolved {
+		i.ResolvedAt = at
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type IssueStatus int
+
+// new statuses are appended so the values of the existing ones stay stable
+const (
+	Created IssueStatus = iota
+	InProgress
+	Resolved
+	Assigned
+	Waitlisted
+	OnHoldCustomer
+	Escalated
+	Reopened
+	Closed
+	Cancelled
+)
+
+var issueStatusNames = map[IssueStatus]string{
+	Created:        "Created",
+	InProgress:     "InProgress",
+	Resolved:       "Resolved",
+	Assigned:       "Assigned",
+	Waitlisted:     "Waitlisted",
+	OnHoldCustomer: "OnHoldCustomer",
+	Escalated:      "Escalated",
+	Reopened:       "Reopened",
+	Closed:         "Closed",
+	Cancelled:      "Cancelled",
+}
+
+func (it IssueStatus) String() string {
+	if name, ok := issueStatusNames[it]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func ParseIssueStatus(s string) (IssueStatus, error) {
+	for status, name := range issueStatusNames {
+		if strings.EqualFold(name, s) {
+			return status, nil
+		}
+	}
+	return Created, fmt.Errorf("unknown issue status %q", s)
+}
+
+func (it IssueStatus) MarshalText() ([]byte, error) {
+	return []byte(it.String()), nil
+}
+
+func (it *IssueStatus) UnmarshalText(text []byte) error {
+	parsed, err := ParseIssueStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*it = parsed
+	return nil
+}
+
+// transitions lists the statuses an issue may move to from each status. Working statuses may
+// transition to themselves so agents can keep adding progress notes, Closed and Cancelled are terminal.
+var transitions = map[IssueStatus][]IssueStatus{
+	Created:        {Assigned, Waitlisted, Cancelled},
+	Waitlisted:     {Assigned, Escalated, Cancelled},
+	Assigned:       {InProgress, OnHoldCustomer, Escalated, Waitlisted, Resolved, Cancelled},
+	InProgress:     {InProgress, OnHoldCustomer, Escalated, Waitlisted, Resolved, Cancelled},
+	OnHoldCustomer: {OnHoldCustomer, InProgress, Escalated, Waitlisted, Resolved, Cancelled},
+	Escalated:      {Escalated, Assigned, InProgress, OnHoldCustomer, Waitlisted, Resolved, Cancelled},
+	Resolved:       {Reopened, Closed},
+	Reopened:       {Assigned, Waitlisted, Cancelled},
+	Closed:         {},
+	Cancelled:      {},
+}
+
+// IsOpen reports whether the issue still needs work, that is neither resolved, closed nor cancelled
+func (it IssueStatus) IsOpen() bool {
+	return it != Resolved && it != Closed && it != Cancelled
+}
+
+func CanTransition(from, to IssueStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+type InvalidTransitionError struct {
+	IssueId string
+	From    IssueStatus
+	To      IssueStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("issue %s cannot move from %s to %s", e.IssueId, e.From, e.To)
+}
+
+func (e *InvalidTransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to IssueStatus
+		want     bool
+	}{
+		{Created, Assigned, true},
+		{Created, Waitlisted, true},
+		{Created, InProgress, false},
+		{Created, Resolved, false},
+		{Waitlisted, Assigned, true},
+		{Waitlisted, Resolved, false},
+		{Assigned, Assigned, false},
+		{Assigned, InProgress, true},
+		{Assigned, Resolved, true},
+		{InProgress, InProgress, true},
+		{InProgress, Assigned, false},
+		{OnHoldCustomer, InProgress, true},
+		{Escalated, Assigned, true},
+		{Resolved, Reopened, true},
+		{Resolved, Closed, true},
+		{Resolved, InProgress, false},
+		{Reopened, Assigned, true},
+		{Reopened, Resolved, false},
+		{Closed, Reopened, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	for _, from := range []IssueStatus{Closed, Cancelled} {
+		for to := range issueStatusNames {
+			if CanTransition(from, to) {
+				t.Errorf("%s is terminal but may move to %s", from, to)
+			}
+		}
+	}
+}
+
+func TestEveryOpenStatusCanBeCancelled(t *testing.T) {
+	for status := range issueStatusNames {
+		if status.IsOpen() && !CanTransition(status, Cancelled) {
+			t.Errorf("open status %s cannot be cancelled", status)
+		}
+	}
+}
+
+func TestUpdateStatusRejectsInvalidTransition(t *testing.T) {
+	issue, err := NewIssue("I1", "T1", "Payment Failed", "money debited", "customer@test.com", Payment, P2)
+	if err != nil {
+		t.Fatalf("NewIssue: %v", err)
+	}
+	_, err = issue.UpdateStatus(Resolved, "refunded")
+	var transitionErr *InvalidTransitionError
+	if !errors.As(err, &transitionErr) || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("UpdateStatus(Resolved) from Created = %v, want an *InvalidTransitionError", err)
+	}
+	if transitionErr.From != Created || transitionErr.To != Resolved {
+		t.Errorf("error reports %s -> %s, want %s -> %s", transitionErr.From, transitionErr.To, Created, Resolved)
+	}
+	if issue.GetStatus() != Created {
+		t.Errorf("status changed to %s after a rejected transition", issue.GetStatus())
+	}
+}
+
+func TestTransitionRecordsMilestones(t *testing.T) {
+	issue, _ := NewIssue("I1", "T1", "Payment Failed", "money debited", "customer@test.com", Payment, P2)
+	issue.CreatedAt = 5
+	steps := []struct {
+		status IssueStatus
+		at     int64
+	}{{Assigned, 10}, {InProgress, 20}, {InProgress, 30}, {Resolved, 40}}
+	for _, step := range steps {
+		if _, err := issue.UpdateStatusAt(step.status, "note", step.at); err != nil {
+			t.Fatalf("UpdateStatusAt(%s): %v", step.status, err)
+		}
+	}
+	_, firstResponseAt, resolvedAt, _ := issue.GetTimestamps()
+	if firstResponseAt != 20 || resolvedAt != 40 {
+		t.Errorf("first response at %d and resolved at %d, want 20 and 40", firstResponseAt, resolvedAt)
+	}
+	if err := issue.ReopenAt("still failing", 50); err != nil {
+		t.Fatalf("ReopenAt: %v", err)
+	}
+	if _, firstResponseAt, resolvedAt, _ := issue.GetTimestamps(); firstResponseAt != 0 || resolvedAt != 0 {
+		t.Errorf("reopened issue has first response at %d and resolved at %d, want both cleared", firstResponseAt, resolvedAt)
+	}
+	if issue.GetReopenCount() != 1 || issue.SLAStartedAt() != 50 {
+		t.Errorf("reopened issue has reopen count %d and SLA start %d, want 1 and 50", issue.GetReopenCount(), issue.SLAStartedAt())
+	}
+}
+
+func TestEscalateOnlyRecordsAllowedTransition(t *testing.T) {
+	issue, _ := NewIssue("I1", "T1", "Payment Failed", "money debited", "customer@test.com", Payment, P2)
+	issue.Escalate(10)
+	if _, _, _, escalatedAt := issue.GetTimestamps(); escalatedAt != 0 || issue.GetStatus() != Created {
+		t.Errorf("escalating a Created issue left it %s escalated at %d, want it untouched", issue.GetStatus(), escalatedAt)
+	}
+	if err := issue.SetStatusAt(Assigned, 20); err != nil {
+		t.Fatalf("SetStatusAt(Assigned): %v", err)
+	}
+	issue.Escalate(30)
+	if _, _, _, escalatedAt := issue.GetTimestamps(); escalatedAt != 30 || issue.GetStatus() != Escalated {
+		t.Errorf("escalating an Assigned issue left it %s escalated at %d, want Escalated at 30", issue.GetStatus(), escalatedAt)
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Presence is whether an agent is around to take work, the zero value is Online
+type Presence int
+
+const (
+	Online  Presence = iota
+	Away             // on a break, keeps its queue but receives no new issues
+	Offline          // off shift, its pending issues are handed to other agents
+	OnLeave          // out of office, like Offline but left alone by shift schedules
+)
+
+var presenceNames = map[Presence]string{
+	Online:  "Online",
+	Away:    "Away",
+	Offline: "Offline",
+	OnLeave: "OnLeave",
+}
+
+func (p Presence) String() string {
+	if name, ok := presenceNames[p]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func ParsePresence(s string) (Presence, error) {
+	for presence, name := range presenceNames {
+		if strings.EqualFold(name, s) {
+			return presence, nil
+		}
+	}
+	return Online, fmt.Errorf("unknown presence %q", s)
+}
+
+func (p Presence) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Presence) UnmarshalText(text []byte) error {
+	parsed, err := ParsePresence(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// AcceptsWork reports whether new issues may be routed to an agent with this presence
+func (p Presence) AcceptsWork() bool {
+	return p == Online
+}
+
+// KeepsQueue reports whether an agent with this presence holds on to its pending issues
+func (p Presence) KeepsQueue() bool {
+	return p == Online || p == Away
+}
+
+// Shift is a weekly working window in the agent's time zone. Start and End are "15:04" wall clock
+// times, an End not after Start means the shift runs past midnight into the next day.
+type Shift struct {
+	Days     []time.Weekday `json:"days"`      // days the shift starts on, 0 is Sunday, empty means every day
+	Start    string         `json:"start"`     // e.g. "09:00"
+	End      string         `json:"end"`       // e.g. "17:30"
+	TimeZone string         `json:"time_zone"` // IANA name such as "Asia/Kolkata", empty means UTC
+}
+
+func (s Shift) Validate() error {
+	if _, _, _, err := s.parse(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAgent, err)
+	}
+	for _, day := range s.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("%w: unknown weekday %d", ErrInvalidAgent, day)
+		}
+	}
+	return nil
+}
+
+// Covers reports whether the instant falls inside the shift
+func (s Shift) Covers(t time.Time) bool {
+	start, end, loc, err := s.parse()
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return s.startsOn(local.Weekday()) && minute >= start && minute < end
+	}
+	// an overnight shift covers the evening of its start day and the morning of the day after
+	yesterday := (local.Weekday() + 6) % 7
+	return (s.startsOn(local.Weekday()) && minute >= start) || (s.startsOn(yesterday) && minute < end)
+}
+
+func (s Shift) startsOn(day time.Weekday) bool {
+	return len(s.Days) == 0 || slices.Contains(s.Days, day)
+}
+
+// parse returns the start and end as minutes after midnight together with the shift's location
+func (s Shift) parse() (int, int, *time.Location, error) {
+	start, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid shift start %q", s.Start)
+	}
+	end, err := time.Parse("15:04", s.End)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid shift end %q", s.End)
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid shift time zone %q", s.TimeZone)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), loc, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Priority orders issues by urgency, P0 being the most urgent. Higher values are more urgent so that
+// issues created before priorities existed (the zero value) default to the lowest priority, P3.
+type Priority int
+
+const (
+	P3 Priority = iota
+	P2
+	P1
+	P0
+)
+
+func (p Priority) String() string {
+	switch p {
+	case P0:
+		return "P0"
+	case P1:
+		return "P1"
+	case P2:
+		return "P2"
+	case P3:
+		return "P3"
+	default:
+		return "Unknown"
+	}
+}
+
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToUpper(s) {
+	case "P0":
+		return P0, nil
+	case "P1":
+		return P1, nil
+	case "P2":
+		return P2, nil
+	case "P3", "":
+		return P3, nil
+	default:
+		return P3, fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// MoreUrgentThan reports whether p should be worked on before other
+func (p Priority) MoreUrgentThan(other Priority) bool {
+	return p > other
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	parsed, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
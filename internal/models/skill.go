@@ -0,0 +1,81 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Proficiency is how skilled an agent is in an issue type, the zero value means no expertise at all
+type Proficiency int
+
+const (
+	NoProficiency Proficiency = iota
+	Trainee
+	Junior
+	Senior
+	Specialist
+)
+
+var proficiencyNames = map[Proficiency]string{
+	NoProficiency: "None",
+	Trainee:       "Trainee",
+	Junior:        "Junior",
+	Senior:        "Senior",
+	Specialist:    "Specialist",
+}
+
+func (p Proficiency) String() string {
+	if name, ok := proficiencyNames[p]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func ParseProficiency(s string) (Proficiency, error) {
+	for level, name := range proficiencyNames {
+		if strings.EqualFold(name, s) {
+			return level, nil
+		}
+	}
+	return NoProficiency, fmt.Errorf("unknown proficiency %q", s)
+}
+
+func (p Proficiency) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Proficiency) UnmarshalText(text []byte) error {
+	parsed, err := ParseProficiency(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Skill is an agent's expertise in one issue type
+type Skill struct {
+	Level Proficiency `json:"level"`
+	// MaxConcurrent caps how many issues of the type the agent holds at once, assigned and pending
+	// together, for the strategies that route by expertise. 0 means no cap.
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// DefaultSkill is the skill given to expertise that was declared without a level
+var DefaultSkill = Skill{Level: Junior}
+
+// UnmarshalJSON also accepts the boolean form expertise used to have, true meaning DefaultSkill
+func (s *Skill) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = DefaultSkill
+		return nil
+	case "false":
+		*s = Skill{}
+		return nil
+	}
+	type plain Skill
+	return json.Unmarshal(data, (*plain)(s))
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EntryKind is what a timeline entry records
+type EntryKind int
+
+const (
+	Comment       EntryKind = iota // a note from an agent the customer may see
+	StatusChange                   // the issue moved along its lifecycle
+	Assignment                     // the issue was handed to an agent, queued with one or parked
+	Reassignment                   // the issue was moved from one agent to another
+	CustomerReply                  // a message from the customer
+	InternalNote                   // a note only agents and supervisors see
+	Duplicate                      // another complaint about the same transaction was linked or merged
+)
+
+var entryKindNames = map[EntryKind]string{
+	Comment:       "Comment",
+	StatusChange:  "StatusChange",
+	Assignment:    "Assignment",
+	Reassignment:  "Reassignment",
+	CustomerReply: "CustomerReply",
+	InternalNote:  "InternalNote",
+	Duplicate:     "Duplicate",
+}
+
+func (k EntryKind) String() string {
+	if name, ok := entryKindNames[k]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func ParseEntryKind(s string) (EntryKind, error) {
+	for kind, name := range entryKindNames {
+		if strings.EqualFold(name, s) {
+			return kind, nil
+		}
+	}
+	return Comment, fmt.Errorf("unknown timeline entry kind %q", s)
+}
+
+func (k EntryKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *EntryKind) UnmarshalText(text []byte) error {
+	parsed, err := ParseEntryKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// IsMessage reports whether entries of the kind are written by people rather than recorded by the workflow
+func (k EntryKind) IsMessage() bool {
+	return k == Comment || k == CustomerReply || k == InternalNote
+}
+
+// SystemAuthor is the author of timeline entries recorded by the workflow itself
+const SystemAuthor = "system"
+
+// TimelineEntry is one record in an issue's append-only timeline
+type TimelineEntry struct {
+	Kind    EntryKind   `json:"kind"`
+	Author  string      `json:"author"`             // agent ID, customer email or SystemAuthor
+	Body    string      `json:"body,omitempty"`     // the message, resolution note or reason
+	Status  IssueStatus `json:"status,omitempty"`   // StatusChange, the status the issue moved to
+	From    string      `json:"from,omitempty"`     // Reassignment, the agent the issue was taken from
+	AgentId string      `json:"agent_id,omitempty"` // Assignment and Reassignment, empty when the issue was parked
+	At      int64       `json:"at"`
+}
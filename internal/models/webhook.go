@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook subscribes an HTTP endpoint to lifecycle events, every delivery is signed with the secret
+type Webhook struct {
+	Id         string      `json:"id"`
+	URL        string      `json:"url"`
+	Events     []string    `json:"events,omitempty"`      // the event types delivered, every type when empty
+	IssueTypes []IssueType `json:"issue_types,omitempty"` // only events about issues of these types, when empty events about any issue or none
+	Secret     string      `json:"secret"`
+	CreatedAt  int64       `json:"created_at"`
+}
+
+// NewWebhook builds a webhook posting to an absolute http or https URL
+func NewWebhook(id, rawURL string, events []string, issueTypes []IssueType, secret string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalidWebhook)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%w: secret cannot be empty", ErrInvalidWebhook)
+	}
+	return &Webhook{
+		Id:         id,
+		URL:        rawURL,
+		Events:     events,
+		IssueTypes: issueTypes,
+		Secret:     secret,
+		CreatedAt:  time.Now().Unix(),
+	}, nil
+}
+
+// DeadLetter is a webhook delivery that failed for good, kept with its payload so that it can be replayed
+type DeadLetter struct {
+	Id        string          `json:"id"` // the delivery ID, replays are sent under the same one
+	WebhookId string          `json:"webhook_id"`
+	EventType string          `json:"event_type"`
+	IssueId   string          `json:"issue_id,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  int64           `json:"failed_at"`
+}
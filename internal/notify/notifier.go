@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to customers
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// DefaultSMTPTimeout bounds a whole SMTP conversation, from dialing the server to QUIT
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTPNotifier sends every message through an SMTP server. net/smtp only authenticates over TLS or to localhost.
+type SMTPNotifier struct {
+	addr    string // host:port
+	from    string
+	auth    smtp.Auth // nil when the server does not require authentication
+	timeout time.Duration
+}
+
+// NewSMTPNotifier sends as from through the server at addr, logging in when a username is given
+func NewSMTPNotifier(addr, from, username, password string) *SMTPNotifier {
+	n := &SMTPNotifier{addr: addr, from: from, timeout: DefaultSMTPTimeout}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// SetTimeout replaces DefaultSMTPTimeout
+func (n *SMTPNotifier) SetTimeout(timeout time.Duration) {
+	n.timeout = timeout
+}
+
+func (n *SMTPNotifier) Notify(msg Message) error {
+	if err := n.send(msg); err != nil {
+		return fmt.Errorf("error occurred while sending email to %s %w", msg.To, err)
+	}
+	return nil
+}
+
+// send does what smtp.SendMail does under a deadline, so that a mail server that stops answering fails the message
+// rather than hang the notification worker
+func (n *SMTPNotifier) send(msg Message) error {
+	conn, err := net.DialTimeout("tcp", n.addr, n.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(n.timeout)); err != nil {
+		return err
+	}
+	host, _, _ := strings.Cut(n.addr, ":")
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.compose(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose builds the RFC 5322 message. The subject is Q-encoded since templates may be localised, and kept on one
+// line since it may quote what the customer wrote.
+func (n *SMTPNotifier) compose(msg Message) []byte {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// WriterNotifier writes every message to a writer instead of sending it, for development and for keeping
+// a record of what would have been sent
+type WriterNotifier struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+func NewConsoleNotifier() *WriterNotifier {
+	return NewWriterNotifier(os.Stdout)
+}
+
+// NewFileNotifier appends the messages to the file at path, creating it if needed. The caller closes the file.
+func NewFileNotifier(path string) (*WriterNotifier, *os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error occurred while opening notification file %w", err)
+	}
+	return NewWriterNotifier(file), file, nil
+}
+
+func (n *WriterNotifier) Notify(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := fmt.Fprintf(n.w, "To: %s\nSubject: %s\n\n%s\n---\n", msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server accepting a single message, without any extension
+type fakeSMTP struct {
+	listener net.Listener
+	from     string
+	rcpt     []string
+	data     string
+	done     chan struct{}
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	srv := &fakeSMTP{listener: listener, done: make(chan struct{})}
+	go srv.serve()
+	return srv
+}
+
+func (srv *fakeSMTP) serve() {
+	defer close(srv.done)
+	conn, err := srv.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 fake")
+		case "MAIL":
+			srv.from = arg
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			srv.rcpt = append(srv.rcpt, arg)
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			srv.data = strings.Join(lines, "\n")
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifierSendsMessage(t *testing.T) {
+	srv := startFakeSMTP(t)
+	n := NewSMTPNotifier(srv.listener.Addr().String(), "support@test.com", "", "")
+	msg := Message{To: "customer@test.com", Subject: "[I1] Tu solicitud ha sido resuelta", Body: "Hola:\n\nResuelta.\n"}
+	if err := n.Notify(msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	<-srv.done
+
+	if srv.from != "FROM:<support@test.com>" || len(srv.rcpt) != 1 || srv.rcpt[0] != "TO:<customer@test.com>" {
+		t.Errorf("envelope is %s %v, want support@test.com to customer@test.com", srv.from, srv.rcpt)
+	}
+	header, body, _ := strings.Cut(srv.data, "\n\n")
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(header + "\n\n")))
+	fields, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("message header does not parse: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(fields.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("subject decodes to %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if fields.Get("To") != msg.To || fields.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("header %v does not address a plain text message to %s", fields, msg.To)
+	}
+	if body != "Hola:\n\nResuelta.\n" {
+		t.Errorf("body is %q", body)
+	}
+}
+
+func TestSMTPNotifierKeepsSubjectOnOneLine(t *testing.T) {
+	n := NewSMTPNotifier("localhost:25", "support@test.com", "", "")
+	composed := string(n.compose(Message{To: "customer@test.com", Subject: "Re: hi\r\nBcc: someone@test.com", Body: "body"}))
+	if strings.Contains(composed, "\r\nBcc:") {
+		t.Errorf("a newline in the subject injected a header:\n%s", composed)
+	}
+}
+
+func TestSMTPNotifierTimesOut(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	// accept the connection and never greet
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	n := NewSMTPNotifier(listener.Addr().String(), "support@test.com", "", "")
+	n.SetTimeout(100 * time.Millisecond)
+	start := time.Now()
+	err = n.Notify(Message{To: "customer@test.com", Subject: "s", Body: "b"})
+	if err == nil {
+		t.Fatal("Notify to a server that never answers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Notify gave up after %s, want about the 100ms timeout", elapsed)
+	}
+}
+
+func TestSMTPNotifierReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	err = NewSMTPNotifier(addr, "support@test.com", "", "").Notify(Message{To: "customer@test.com", Subject: "s", Body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "customer@test.com") {
+		t.Errorf("Notify to a closed port = %v, want an error naming the recipient", err)
+	}
+}
+
+func TestWriterNotifier(t *testing.T) {
+	var b strings.Builder
+	if err := NewWriterNotifier(&b).Notify(Message{To: "customer@test.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if want := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n---\n", "customer@test.com", "s", "b"); b.String() != want {
+		t.Errorf("wrote %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,586 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iss/internal/models"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FileStore keeps issues, agents, assignments, customers, webhooks and dead letters in journals inside a directory so
+// they survive restarts. Every write appends one record to the affected journal, see journal.
+type FileStore struct {
+	Issues      *FileIssueRepository
+	Agents      *FileAgentRepository
+	Assignments *FileAssignmentRepository
+	Customers   *FileCustomerRepository
+	Webhooks    *FileWebhookRepository
+	DeadLetters *FileDeadLetterRepository
+}
+
+// NewFileStore opens the store in dir. A store written before the journals, one JSON document per repository, is
+// imported on the first start.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error occurred while creating store directory %w", err)
+	}
+	issues, err := NewFileIssueRepository(filepath.Join(dir, "issues.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	agents, err := NewFileAgentRepository(filepath.Join(dir, "agents.jsonl"), issues)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := NewFileAssignmentRepository(filepath.Join(dir, "assignments.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	customers, err := NewFileCustomerRepository(filepath.Join(dir, "customers.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := NewFileWebhookRepository(filepath.Join(dir, "webhooks.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, err := NewFileDeadLetterRepository(filepath.Join(dir, "dead_letters.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		Issues:      issues,
+		Agents:      agents,
+		Assignments: assignments,
+		Customers:   customers,
+		Webhooks:    webhooks,
+		DeadLetters: deadLetters,
+	}, nil
+}
+
+func (s *FileStore) Close() error {
+	return errors.Join(
+		s.Issues.Close(),
+		s.Agents.Close(),
+		s.Assignments.Close(),
+		s.Customers.Close(),
+		s.Webhooks.Close(),
+		s.DeadLetters.Close(),
+	)
+}
+
+type FileIssueRepository struct {
+	*MemoryIssueRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+func NewFileIssueRepository(path string) (*FileIssueRepository, error) {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileIssueRepository{MemoryIssueRepository: NewMemoryIssueRepository(), journal: j}
+	issues, err := decodeRecords[*models.Issue](path, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := importLegacy(j, &issues, func() map[string]any { return keyed(issues, func(issue *models.Issue) string { return issue.Id }) }); err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		r.MemoryIssueRepository.Save(issue)
+	}
+	return r, nil
+}
+
+func (r *FileIssueRepository) Save(issue *models.Issue) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryIssueRepository.Save(issue)
+	if err := r.journal.put(issue.Id, issue.Snapshot()); err != nil {
+		return err
+	}
+	if r.journal.stale() {
+		return r.journal.compact(r.snapshot())
+	}
+	return nil
+}
+
+// snapshot copies every issue under its lock, by ID
+func (r *FileIssueRepository) snapshot() map[string]any {
+	records := make(map[string]any)
+	for _, issue := range r.List() {
+		records[issue.Id] = issue.Snapshot()
+	}
+	return records
+}
+
+func (r *FileIssueRepository) Close() error {
+	return r.journal.Close()
+}
+
+// agentRecord is the on-disk form of an agent, issues are referenced by their ID
+type agentRecord struct {
+	Id               string                            `json:"id"`
+	Name             string                            `json:"name"`
+	Email            string                            `json:"email"`
+	Expertise        map[models.IssueType]models.Skill `json:"expertise"`
+	Capacity         int                               `json:"capacity"`
+	Presence         models.Presence                   `json:"presence"`
+	Shifts           []models.Shift                    `json:"shifts,omitempty"`
+	ActiveIssueIds   []string                          `json:"active_issue_ids"`
+	AssignedIssueId  string                            `json:"assigned_issue_id,omitempty"` // only read, stores from before capacities
+	PendingIssueIds  []string                          `json:"pending_issue_ids"`
+	ResolvedIssueIds []string                          `json:"resolved_issue_ids"`
+	ReopenedIssues   map[string]int                    `json:"reopened_issues,omitempty"`
+	CreatedAt        int64                             `json:"created_at"`
+	DeactivatedAt    int64                             `json:"deactivated_at,omitempty"`
+	Removed          bool                              `json:"removed,omitempty"`
+}
+
+type FileAgentRepository struct {
+	*MemoryAgentRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+// NewFileAgentRepository loads the agents stored at path, resolving their issues through the given issue repository
+func NewFileAgentRepository(path string, issues IssueRepository) (*FileAgentRepository, error) {
+	j, stored, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileAgentRepository{MemoryAgentRepository: NewMemoryAgentRepository(), journal: j}
+	records, err := decodeRecords[agentRecord](path, stored)
+	if err != nil {
+		return nil, err
+	}
+	imported := func() map[string]any { return keyed(records, func(record agentRecord) string { return record.Id }) }
+	if err := importLegacy(j, &records, imported); err != nil {
+		return nil, err
+	}
+	lookup := func(id string) (*models.Issue, error) {
+		issue := issues.Get(id)
+		if issue == nil {
+			return nil, fmt.Errorf("issue %s referenced by an agent is missing from the store", id)
+		}
+		return issue, nil
+	}
+	for _, record := range records {
+		agent := &models.Agent{
+			Id:             record.Id,
+			Name:           record.Name,
+			Email:          record.Email,
+			Expertise:      record.Expertise,
+			Capacity:       record.Capacity,
+			Presence:       record.Presence,
+			Shifts:         record.Shifts,
+			ActiveIssues:   make(map[string]*models.Issue),
+			PendingIssues:  []*models.Issue{},
+			ResolvedIssues: make(map[string]*models.Issue),
+			ReopenedIssues: make(map[string]int),
+			HeapIndex:      -1,
+			CreatedAt:      record.CreatedAt,
+			DeactivatedAt:  record.DeactivatedAt,
+			Removed:        record.Removed,
+		}
+		if agent.Capacity == 0 {
+			agent.Capacity = models.DefaultCapacity
+		}
+		if record.AssignedIssueId != "" {
+			record.ActiveIssueIds = append(record.ActiveIssueIds, record.AssignedIssueId)
+		}
+		for _, id := range record.ActiveIssueIds {
+			issue, err := lookup(id)
+			if err != nil {
+				return nil, err
+			}
+			agent.ActiveIssues[id] = issue
+		}
+		for _, id := range record.PendingIssueIds {
+			issue, err := lookup(id)
+			if err != nil {
+				return nil, err
+			}
+			agent.PendingIssues = append(agent.PendingIssues, issue)
+		}
+		for _, id := range record.ResolvedIssueIds {
+			issue, err := lookup(id)
+			if err != nil {
+				return nil, err
+			}
+			agent.ResolvedIssues[id] = issue
+		}
+		for id, count := range record.ReopenedIssues {
+			agent.ReopenedIssues[id] = count
+		}
+		r.MemoryAgentRepository.Save(agent)
+	}
+	return r, nil
+}
+
+func (r *FileAgentRepository) Save(agent *models.Agent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryAgentRepository.Save(agent)
+	if err := r.journal.put(agent.Id, toAgentRecord(agent)); err != nil {
+		return err
+	}
+	if r.journal.stale() {
+		records := make(map[string]any)
+		for _, agent := range r.List() {
+			records[agent.Id] = toAgentRecord(agent)
+		}
+		return r.journal.compact(records)
+	}
+	return nil
+}
+
+func (r *FileAgentRepository) Close() error {
+	return r.journal.Close()
+}
+
+// toAgentRecord reads the agent from a snapshot taken under its lock, issues are only referenced by their immutable ID
+func toAgentRecord(agent *models.Agent) agentRecord {
+	agent = agent.Snapshot()
+	record := agentRecord{
+		Id:               agent.Id,
+		Name:             agent.Name,
+		Email:            agent.Email,
+		Expertise:        agent.Expertise,
+		Capacity:         agent.Capacity,
+		Presence:         agent.Presence,
+		Shifts:           agent.Shifts,
+		ActiveIssueIds:   slices.Sorted(maps.Keys(agent.ActiveIssues)),
+		PendingIssueIds:  []string{},
+		ResolvedIssueIds: slices.Sorted(maps.Keys(agent.ResolvedIssues)),
+		ReopenedIssues:   agent.ReopenedIssues,
+		CreatedAt:        agent.CreatedAt,
+		DeactivatedAt:    agent.DeactivatedAt,
+		Removed:          agent.Removed,
+	}
+	for _, issue := range agent.PendingIssues {
+		record.PendingIssueIds = append(record.PendingIssueIds, issue.Id)
+	}
+	return record
+}
+
+type FileAssignmentRepository struct {
+	*MemoryAssignmentRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+func NewFileAssignmentRepository(path string) (*FileAssignmentRepository, error) {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileAssignmentRepository{MemoryAssignmentRepository: NewMemoryAssignmentRepository(), journal: j}
+	assignments := make(map[string]string, len(records))
+	for issueId, data := range records {
+		var agentId string
+		if err := json.Unmarshal(data, &agentId); err != nil {
+			return nil, fmt.Errorf("error occurred while decoding %s %w", path, err)
+		}
+		assignments[issueId] = agentId
+	}
+	imported := func() map[string]any {
+		byIssue := make(map[string]any, len(assignments))
+		for issueId, agentId := range assignments {
+			byIssue[issueId] = agentId
+		}
+		return byIssue
+	}
+	if err := importLegacy(j, &assignments, imported); err != nil {
+		return nil, err
+	}
+	for issueId, agentId := range assignments {
+		r.MemoryAssignmentRepository.Set(issueId, agentId)
+	}
+	return r, nil
+}
+
+func (r *FileAssignmentRepository) Set(issueId, agentId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryAssignmentRepository.Set(issueId, agentId)
+	if err := r.journal.put(issueId, agentId); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileAssignmentRepository) Delete(issueId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryAssignmentRepository.Delete(issueId)
+	if err := r.journal.delete(issueId); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileAssignmentRepository) compactIfStale() error {
+	if !r.journal.stale() {
+		return nil
+	}
+	records := make(map[string]any)
+	for issueId, agentId := range r.All() {
+		records[issueId] = agentId
+	}
+	return r.journal.compact(records)
+}
+
+func (r *FileAssignmentRepository) Close() error {
+	return r.journal.Close()
+}
+
+type FileCustomerRepository struct {
+	*MemoryCustomerRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+func NewFileCustomerRepository(path string) (*FileCustomerRepository, error) {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileCustomerRepository{MemoryCustomerRepository: NewMemoryCustomerRepository(), journal: j}
+	customers, err := decodeRecords[*models.Customer](path, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := importLegacy(j, &customers, func() map[string]any {
+		return keyed(customers, func(customer *models.Customer) string { return customer.Id })
+	}); err != nil {
+		return nil, err
+	}
+	for _, customer := range customers {
+		r.MemoryCustomerRepository.Save(customer)
+	}
+	return r, nil
+}
+
+func (r *FileCustomerRepository) Save(customer *models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryCustomerRepository.Save(customer)
+	if err := r.journal.put(customer.Id, customer.Snapshot()); err != nil {
+		return err
+	}
+	if r.journal.stale() {
+		return r.journal.compact(r.snapshot())
+	}
+	return nil
+}
+
+// snapshot copies every customer under its lock, by ID
+func (r *FileCustomerRepository) snapshot() map[string]any {
+	records := make(map[string]any)
+	for _, customer := range r.List() {
+		records[customer.Id] = customer.Snapshot()
+	}
+	return records
+}
+
+func (r *FileCustomerRepository) Close() error {
+	return r.journal.Close()
+}
+
+// FileWebhookRepository journals webhooks as they are, they do not change once created
+type FileWebhookRepository struct {
+	*MemoryWebhookRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+func NewFileWebhookRepository(path string) (*FileWebhookRepository, error) {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileWebhookRepository{MemoryWebhookRepository: NewMemoryWebhookRepository(), journal: j}
+	webhooks, err := decodeRecords[*models.Webhook](path, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := importLegacy(j, &webhooks, func() map[string]any {
+		return keyed(webhooks, func(webhook *models.Webhook) string { return webhook.Id })
+	}); err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		r.MemoryWebhookRepository.Save(webhook)
+	}
+	return r, nil
+}
+
+func (r *FileWebhookRepository) Save(webhook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryWebhookRepository.Save(webhook)
+	if err := r.journal.put(webhook.Id, webhook); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileWebhookRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryWebhookRepository.Delete(id)
+	if err := r.journal.delete(id); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileWebhookRepository) compactIfStale() error {
+	if !r.journal.stale() {
+		return nil
+	}
+	return r.journal.compact(r.snapshot())
+}
+
+func (r *FileWebhookRepository) snapshot() map[string]any {
+	records := make(map[string]any)
+	for _, webhook := range r.List() {
+		records[webhook.Id] = webhook
+	}
+	return records
+}
+
+func (r *FileWebhookRepository) Close() error {
+	return r.journal.Close()
+}
+
+// FileDeadLetterRepository journals dead letters as they are, they do not change once created
+type FileDeadLetterRepository struct {
+	*MemoryDeadLetterRepository
+	journal *journal
+	mu      sync.Mutex
+}
+
+func NewFileDeadLetterRepository(path string) (*FileDeadLetterRepository, error) {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileDeadLetterRepository{MemoryDeadLetterRepository: NewMemoryDeadLetterRepository(), journal: j}
+	letters, err := decodeRecords[*models.DeadLetter](path, records)
+	if err != nil {
+		return nil, err
+	}
+	if err := importLegacy(j, &letters, func() map[string]any {
+		return keyed(letters, func(letter *models.DeadLetter) string { return letter.Id })
+	}); err != nil {
+		return nil, err
+	}
+	for _, letter := range letters {
+		r.MemoryDeadLetterRepository.Save(letter)
+	}
+	return r, nil
+}
+
+func (r *FileDeadLetterRepository) Save(letter *models.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryDeadLetterRepository.Save(letter)
+	if err := r.journal.put(letter.Id, letter); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileDeadLetterRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MemoryDeadLetterRepository.Delete(id)
+	if err := r.journal.delete(id); err != nil {
+		return err
+	}
+	return r.compactIfStale()
+}
+
+func (r *FileDeadLetterRepository) compactIfStale() error {
+	if !r.journal.stale() {
+		return nil
+	}
+	return r.journal.compact(r.snapshot())
+}
+
+func (r *FileDeadLetterRepository) snapshot() map[string]any {
+	records := make(map[string]any)
+	for _, letter := range r.List() {
+		records[letter.Id] = letter
+	}
+	return records
+}
+
+func (r *FileDeadLetterRepository) Close() error {
+	return r.journal.Close()
+}
+
+// decodeRecords decodes the journalled records ordered by key
+func decodeRecords[T any](path string, records map[string]json.RawMessage) ([]T, error) {
+	values := make([]T, 0, len(records))
+	for _, key := range slices.Sorted(maps.Keys(records)) {
+		var value T
+		if err := json.Unmarshal(records[key], &value); err != nil {
+			return nil, fmt.Errorf("error occurred while decoding %s record %s %w", path, key, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// keyed maps the freshly decoded values by their key for compact
+func keyed[T any](values []T, key func(T) string) map[string]any {
+	records := make(map[string]any, len(values))
+	for _, value := range values {
+		records[key(value)] = value
+	}
+	return records
+}
+
+// importLegacy reads the JSON document a store kept next to a new journal before the journals, issues.json for
+// issues.jsonl, into v. The records loaded from it are compacted into the journal and the document is removed.
+// Nothing happens once the journal holds records.
+func importLegacy(j *journal, v any, loaded func() map[string]any) error {
+	legacy, ok := strings.CutSuffix(j.path, ".jsonl")
+	if !ok || j.lines > 0 {
+		return nil
+	}
+	legacy += ".json"
+	if _, err := os.Stat(legacy); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := readJSONFile(legacy, v); err != nil {
+		return err
+	}
+	if err := j.compact(loaded()); err != nil {
+		return err
+	}
+	fmt.Printf("imported %s into %s \n", legacy, j.path)
+	return os.Remove(legacy)
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error occurred while reading %s %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error occurred while decoding %s %w", path, err)
+	}
+	return nil
+}
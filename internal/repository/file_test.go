@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"iss/internal/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newIssue(t *testing.T, id string) *models.Issue {
+	t.Helper()
+	issue, err := models.NewIssue(id, "T-"+id, "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2)
+	if err != nil {
+		t.Fatalf("NewIssue: %v", err)
+	}
+	return issue
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	return lines
+}
+
+func TestFileStoreReopens(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	issue := newIssue(t, "I1")
+	agent, _ := models.NewAgent("A1", "Asha", "asha@test.com", map[models.IssueType]models.Skill{models.Payment: models.DefaultSkill}, 2)
+	if err := agent.AssignIssue(issue); err != nil {
+		t.Fatalf("AssignIssue: %v", err)
+	}
+	issue.SetStatus(models.Assigned)
+	store.Issues.Save(issue)
+	store.Agents.Save(agent)
+	store.Assignments.Set("I1", "A1")
+	store.Assignments.Set("I2", "A1")
+	store.Assignments.Delete("I2")
+	store.Close()
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore after restart: %v", err)
+	}
+	defer store.Close()
+	if got := store.Issues.Get("I1"); got == nil || got.GetStatus() != models.Assigned {
+		t.Errorf("issue after restart = %v, want it Assigned", got)
+	}
+	if got := store.Agents.Get("A1"); got == nil || !got.HasActiveIssue("I1") || got.GetActiveIssues()[0] != store.Issues.Get("I1") {
+		t.Errorf("agent after restart does not work on the stored I1")
+	}
+	if got := store.Assignments.All(); len(got) != 1 || got["I1"] != "A1" {
+		t.Errorf("assignments after restart = %v, want only I1 with A1", got)
+	}
+}
+
+func TestSaveAppendsAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "issues.jsonl")
+	r, err := NewFileIssueRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileIssueRepository: %v", err)
+	}
+	defer r.Close()
+	issue := newIssue(t, "I1")
+	r.Save(issue)
+	r.Save(newIssue(t, "I2"))
+	if lines := countLines(t, path); lines != 2 {
+		t.Fatalf("journal holds %d lines after two saves, want 2", lines)
+	}
+	for range 100 {
+		r.Save(issue)
+	}
+	if lines := countLines(t, path); lines > 2*2+compactSlack {
+		t.Errorf("journal holds %d lines for 2 issues, it was not compacted", lines)
+	}
+
+	reopened, err := NewFileIssueRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileIssueRepository after compaction: %v", err)
+	}
+	defer reopened.Close()
+	if len(reopened.List()) != 2 {
+		t.Errorf("compacted journal holds %d issues, want 2", len(reopened.List()))
+	}
+}
+
+func TestTornRecordIsDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.jsonl")
+	r, _ := NewFileWebhookRepository(path)
+	webhook, _ := models.NewWebhook("W1", "https://example.com/hook", nil, nil, "secret")
+	r.Save(webhook)
+	r.Close()
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	file.WriteString(`{"key":"W2","value":{"id":"W2"`)
+	file.Close()
+
+	r, err := NewFileWebhookRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileWebhookRepository with a torn record: %v", err)
+	}
+	defer r.Close()
+	if len(r.List()) != 1 || r.Get("W1") == nil {
+		t.Errorf("webhooks = %v, want only W1", r.List())
+	}
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("journal holds %d lines, want the torn one truncated away", lines)
+	}
+}
+
+func TestLegacyDocumentIsImported(t *testing.T) {
+	dir := t.TempDir()
+	legacy, _ := json.Marshal(map[string]string{"I1": "A1", "I2": "A2"})
+	if err := os.WriteFile(filepath.Join(dir, "assignments.json"), legacy, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	r, err := NewFileAssignmentRepository(filepath.Join(dir, "assignments.jsonl"))
+	if err != nil {
+		t.Fatalf("NewFileAssignmentRepository: %v", err)
+	}
+	r.Delete("I2")
+	r.Close()
+	if _, err := os.Stat(filepath.Join(dir, "assignments.json")); !os.IsNotExist(err) {
+		t.Errorf("legacy document was kept after the import")
+	}
+
+	r, _ = NewFileAssignmentRepository(filepath.Join(dir, "assignments.jsonl"))
+	defer r.Close()
+	if got := r.All(); len(got) != 1 || got["I1"] != "A1" {
+		t.Errorf("assignments = %v, want only I1 with A1", got)
+	}
+}
+
+// run with -race, the issue and customer change while they are written
+func TestSaveSnapshotsUnderLock(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer store.Close()
+	issue := newIssue(t, "I1")
+	customer, _ := models.NewCustomer("C1", []string{"customer@test.com"}, "", models.Standard, "", 0)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			issue.AppendTimeline(models.TimelineEntry{Kind: models.Comment, Author: "A1", Body: "checking", At: int64(i)})
+			customer.Contacted(int64(i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			store.Issues.Save(issue)
+			store.Customers.Save(customer)
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// compactSlack is how many stale lines a journal may hold beyond its live records before it is compacted, so that
+// small stores are not rewritten on every other write
+const compactSlack = 64
+
+// journal keeps records as JSON lines appended to a file, one line per save or delete, the last line of a key wins.
+// A write costs one line. Once the stale lines outnumber the live records the file is rewritten with only the live
+// ones, which keeps the file proportional to the store and the writes O(1) amortised.
+type journal struct {
+	path  string
+	file  *os.File
+	keys  map[string]bool // the live keys
+	lines int
+}
+
+// journalLine is the on-disk form of one save or delete
+type journalLine struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+// openJournal opens the journal at path, creating it if needed, and returns the live records by key. A partially
+// written final line, which is what a crash in the middle of a write leaves behind, is truncated away.
+func openJournal(path string) (*journal, map[string]json.RawMessage, error) {
+	j := &journal{path: path, keys: make(map[string]bool)}
+	records := make(map[string]json.RawMessage)
+	size, err := j.scan(func(line journalLine) {
+		if line.Deleted {
+			delete(records, line.Key)
+		} else {
+			records[line.Key] = line.Value
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error occurred while opening %s %w", path, err)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error occurred while truncating %s %w", path, err)
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error occurred while opening %s %w", path, err)
+	}
+	j.file = file
+	for key := range records {
+		j.keys[key] = true
+	}
+	return j, records, nil
+}
+
+// scan calls fn for every complete line and returns the size of the journal up to the last complete line
+func (j *journal) scan(fn func(line journalLine)) (int64, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error occurred while opening %s %w", j.path, err)
+	}
+	defer file.Close()
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				fmt.Printf("ignoring torn record at the end of %s \n", j.path)
+			}
+			return size, nil
+		}
+		if err != nil {
+			return size, fmt.Errorf("error occurred while reading %s %w", j.path, err)
+		}
+		var line journalLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return size, fmt.Errorf("error occurred while decoding %s at offset %d %w", j.path, size, err)
+		}
+		fn(line)
+		j.lines++
+		size += int64(len(data))
+	}
+}
+
+// put appends the value as the record of key
+func (j *journal) put(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error occurred while encoding %s %w", j.path, err)
+	}
+	if err := j.append(journalLine{Key: key, Value: data}); err != nil {
+		return err
+	}
+	j.keys[key] = true
+	return nil
+}
+
+// delete appends the removal of key
+func (j *journal) delete(key string) error {
+	if !j.keys[key] {
+		return nil
+	}
+	if err := j.append(journalLine{Key: key, Deleted: true}); err != nil {
+		return err
+	}
+	delete(j.keys, key)
+	return nil
+}
+
+func (j *journal) append(line journalLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("error occurred while encoding %s %w", j.path, err)
+	}
+	offset, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error occurred while writing %s %w", j.path, err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		// drop what was written of the line, it would otherwise end up in the middle of the file
+		if err := j.file.Truncate(offset); err == nil {
+			j.file.Seek(offset, io.SeekStart)
+		}
+		return fmt.Errorf("error occurred while writing %s %w", j.path, err)
+	}
+	j.lines++
+	return nil
+}
+
+// stale reports whether compacting the journal pays off
+func (j *journal) stale() bool {
+	return j.lines > 2*len(j.keys)+compactSlack
+}
+
+// compact replaces the journal with one line per live record, atomically (temp file + rename)
+func (j *journal) compact(records map[string]any) error {
+	var sb strings.Builder
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value, err := json.Marshal(records[key])
+		if err != nil {
+			return fmt.Errorf("error occurred while encoding %s %w", j.path, err)
+		}
+		data, err := json.Marshal(journalLine{Key: key, Value: value})
+		if err != nil {
+			return fmt.Errorf("error occurred while encoding %s %w", j.path, err)
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("error occurred while compacting %s %w", j.path, err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("error occurred while compacting %s %w", j.path, err)
+	}
+	file, err := os.OpenFile(j.path, os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error occurred while opening %s %w", j.path, err)
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return fmt.Errorf("error occurred while opening %s %w", j.path, err)
+	}
+	j.file.Close()
+	j.file = file
+	j.lines = len(keys)
+	j.keys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		j.keys[key] = true
+	}
+	return nil
+}
+
+func (j *journal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"iss/internal/models"
+	"sync"
+)
+
+type MemoryIssueRepository struct {
+	issues map[string]*models.Issue
+	mu     sync.RWMutex
+}
+
+func NewMemoryIssueRepository() *MemoryIssueRepository {
+	return &MemoryIssueRepository{
+		issues: make(map[string]*models.Issue),
+	}
+}
+
+func (r *MemoryIssueRepository) Save(issue *models.Issue) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issues[issue.Id] = issue
+	return nil
+}
+
+func (r *MemoryIssueRepository) Get(id string) *models.Issue {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.issues[id]
+}
+
+func (r *MemoryIssueRepository) List() []*models.Issue {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	issues := make([]*models.Issue, 0, len(r.issues))
+	for _, issue := range r.issues {
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+type MemoryAgentRepository struct {
+	agents map[string]*models.Agent
+	mu     sync.RWMutex
+}
+
+func NewMemoryAgentRepository() *MemoryAgentRepository {
+	return &MemoryAgentRepository{
+		agents: make(map[string]*models.Agent),
+	}
+}
+
+func (r *MemoryAgentRepository) Save(agent *models.Agent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.agents[agent.Id] = agent
+	return nil
+}
+
+func (r *MemoryAgentRepository) Get(id string) *models.Agent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.agents[id]
+}
+
+func (r *MemoryAgentRepository) List() []*models.Agent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	agents := make([]*models.Agent, 0, len(r.agents))
+	for _, agent := range r.agents {
+		agents = append(agents, agent)
+	}
+	return agents
+}
+
+type MemoryAssignmentRepository struct {
+	issueAgentMap map[string]string
+	mu            sync.RWMutex
+}
+
+func NewMemoryAssignmentRepository() *MemoryAssignmentRepository {
+	return &MemoryAssignmentRepository{
+		issueAgentMap: make(map[string]string),
+	}
+}
+
+func (r *MemoryAssignmentRepository) Set(issueId, agentId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issueAgentMap[issueId] = agentId
+	return nil
+}
+
+func (r *MemoryAssignmentRepository) Delete(issueId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.issueAgentMap, issueId)
+	return nil
+}
+
+func (r *MemoryAssignmentRepository) All() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	all := make(map[string]string, len(r.issueAgentMap))
+	for issueId, agentId := range r.issueAgentMap {
+		all[issueId] = agentId
+	}
+	return all
+}
+
+type MemoryCustomerRepository struct {
+	customers map[string]*models.Customer
+	mu        sync.RWMutex
+}
+
+func NewMemoryCustomerRepository() *MemoryCustomerRepository {
+	return &MemoryCustomerRepository{
+		customers: make(map[string]*models.Customer),
+	}
+}
+
+func (r *MemoryCustomerRepository) Save(customer *models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.customers[customer.Id] = customer
+	return nil
+}
+
+func (r *MemoryCustomerRepository) Get(id string) *models.Customer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.customers[id]
+}
+
+func (r *MemoryCustomerRepository) List() []*models.Customer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	customers := make([]*models.Customer, 0, len(r.customers))
+	for _, customer := range r.customers {
+		customers = append(customers, customer)
+	}
+	return customers
+}
+
+type MemoryWebhookRepository struct {
+	webhooks map[string]*models.Webhook
+	mu       sync.RWMutex
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		webhooks: make(map[string]*models.Webhook),
+	}
+}
+
+func (r *MemoryWebhookRepository) Save(webhook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks[webhook.Id] = webhook
+	return nil
+}
+
+func (r *MemoryWebhookRepository) Get(id string) *models.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.webhooks[id]
+}
+
+func (r *MemoryWebhookRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) List() []*models.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	webhooks := make([]*models.Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+type MemoryDeadLetterRepository struct {
+	letters map[string]*models.DeadLetter
+	mu      sync.RWMutex
+}
+
+func NewMemoryDeadLetterRepository() *MemoryDeadLetterRepository {
+	return &MemoryDeadLetterRepository{
+		letters: make(map[string]*models.DeadLetter),
+	}
+}
+
+func (r *MemoryDeadLetterRepository) Save(letter *models.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.letters[letter.Id] = letter
+	return nil
+}
+
+func (r *MemoryDeadLetterRepository) Get(id string) *models.DeadLetter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.letters[id]
+}
+
+func (r *MemoryDeadLetterRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.letters, id)
+	return nil
+}
+
+func (r *MemoryDeadLetterRepository) List() []*models.DeadLetter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	letters := make([]*models.DeadLetter, 0, len(r.letters))
+	for _, letter := range r.letters {
+		letters = append(letters, letter)
+	}
+	return letters
+}
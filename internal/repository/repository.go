@@ -0,0 +1,47 @@
+package repository
+
+import "iss/internal/models"
+
+// IssueRepository stores issues by their ID
+type IssueRepository interface {
+	Save(issue *models.Issue) error
+	Get(id string) *models.Issue
+	List() []*models.Issue
+}
+
+// AgentRepository stores agents by their ID, including their assigned, pending and resolved issues
+type AgentRepository interface {
+	Save(agent *models.Agent) error
+	Get(id string) *models.Agent
+	List() []*models.Agent
+}
+
+// AssignmentRepository stores which agent an issue has been handed to
+type AssignmentRepository interface {
+	Set(issueId, agentId string) error
+	Delete(issueId string) error
+	All() map[string]string
+}
+
+// CustomerRepository stores customers by their ID
+type CustomerRepository interface {
+	Save(customer *models.Customer) error
+	Get(id string) *models.Customer
+	List() []*models.Customer
+}
+
+// WebhookRepository stores webhook subscriptions by their ID
+type WebhookRepository interface {
+	Save(webhook *models.Webhook) error
+	Get(id string) *models.Webhook
+	Delete(id string) error
+	List() []*models.Webhook
+}
+
+// DeadLetterRepository stores the webhook deliveries that failed for good by their delivery ID
+type DeadLetterRepository interface {
+	Save(letter *models.DeadLetter) error
+	Get(id string) *models.DeadLetter
+	Delete(id string) error
+	List() []*models.DeadLetter
+}
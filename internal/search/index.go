@@ -0,0 +1,127 @@
+// Package search keeps an inverted index over text documents and ranks them against free-text queries with BM25
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// BM25 parameters, k1 limits how much repeating a term keeps raising a score and b how much long documents
+// are penalised
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Hit is a document matching a query, with its BM25 score
+type Hit struct {
+	Id    string
+	Score float64
+}
+
+// Index maps every term to the documents holding it. It is not safe for concurrent use.
+type Index struct {
+	postings map[string]map[string]int // term -> document -> term frequency
+	docs     map[string]map[string]int // document -> term -> term frequency, to take a document out again
+	lengths  map[string]int
+	total    int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+	}
+}
+
+// Put indexes the document under the terms of its text, replacing whatever it was indexed under before
+func (ix *Index) Put(id string, text ...string) {
+	ix.Remove(id)
+	terms := make(map[string]int)
+	length := 0
+	for _, t := range text {
+		for _, term := range Tokenize(t) {
+			terms[term]++
+			length++
+		}
+	}
+	for term, freq := range terms {
+		docs, ok := ix.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			ix.postings[term] = docs
+		}
+		docs[id] = freq
+	}
+	ix.docs[id] = terms
+	ix.lengths[id] = length
+	ix.total += length
+}
+
+func (ix *Index) Remove(id string) {
+	terms, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for term := range terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.total -= ix.lengths[id]
+	delete(ix.docs, id)
+	delete(ix.lengths, id)
+}
+
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Search returns the documents holding any term of the query that keep passes, best match first and ties by id.
+// A nil keep keeps every document, a limit of 0 or less returns every hit.
+func (ix *Index) Search(query string, keep func(id string) bool, limit int) []Hit {
+	if len(ix.docs) == 0 {
+		return []Hit{}
+	}
+	n := float64(len(ix.docs))
+	avgLength := float64(ix.total) / n
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		docs := ix.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, freq := range docs {
+			tf := float64(freq)
+			norm := k1 * (1 - b + b*float64(ix.lengths[id])/avgLength)
+			scores[id] += idf * tf * (k1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		if keep == nil || keep(id) {
+			hits = append(hits, Hit{Id: id, Score: score})
+		}
+	}
+	slices.SortFunc(hits, func(x, y Hit) int {
+		if c := cmp.Compare(y.Score, x.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.Id, y.Id)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func hitIds(hits []Hit) []string {
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func TestSearchScoresBM25(t *testing.T) {
+	ix := NewIndex()
+	ix.Put("I1", "refund")
+	ix.Put("I2", "gold")
+
+	// one of two documents of length 1 at the average length: idf is ln 2 and the term frequency part is 1
+	hits := ix.Search("refund", nil, 0)
+	if len(hits) != 1 || hits[0].Id != "I1" {
+		t.Fatalf("Search(refund) = %v, want only I1", hits)
+	}
+	if want := math.Log(2); math.Abs(hits[0].Score-want) > 1e-9 {
+		t.Errorf("score = %v, want %v", hits[0].Score, want)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  map[string]string
+		query string
+		want  []string
+	}{
+		{
+			name: "repeated term ranks higher",
+			docs: map[string]string{
+				"I1": "payment failed",
+				"I2": "payment payment failed",
+				"I3": "gold purchase",
+			},
+			query: "payment",
+			want:  []string{"I2", "I1"},
+		},
+		{
+			name: "rare term outweighs common term",
+			docs: map[string]string{
+				"I1": "payment refund",
+				"I2": "payment delayed",
+				"I3": "payment pending",
+				"I4": "payment mandate",
+			},
+			query: "refund payment",
+			want:  []string{"I1", "I2", "I3", "I4"},
+		},
+		{
+			name: "shorter document ranks higher",
+			docs: map[string]string{
+				"I1": "mandate rejected by the bank after three retries overnight",
+				"I2": "mandate rejected",
+				"I3": "gold purchase",
+			},
+			query: "mandate",
+			want:  []string{"I2", "I1"},
+		},
+		{
+			name: "inflections match",
+			docs: map[string]string{
+				"I1": "card used twice",
+				"I2": "gold purchase",
+			},
+			query: "uses",
+			want:  []string{"I1"},
+		},
+		{
+			name: "ties by id",
+			docs: map[string]string{
+				"I2": "refund pending",
+				"I1": "refund pending",
+				"I3": "gold purchase",
+			},
+			query: "refund",
+			want:  []string{"I1", "I2"},
+		},
+	}
+	for _, tt := range tests {
+		ix := NewIndex()
+		for id, text := range tt.docs {
+			ix.Put(id, text)
+		}
+		if got := hitIds(ix.Search(tt.query, nil, 0)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Search(%q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchKeepLimitAndRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Put("I1", "refund pending")
+	ix.Put("I2", "refund refund pending")
+	ix.Put("I3", "refund failed")
+
+	if got := hitIds(ix.Search("refund", func(id string) bool { return id != "I2" }, 0)); !slices.Equal(got, []string{"I1", "I3"}) {
+		t.Errorf("Search with keep = %v, want [I1 I3]", got)
+	}
+	if got := hitIds(ix.Search("refund", nil, 1)); !slices.Equal(got, []string{"I2"}) {
+		t.Errorf("Search with limit 1 = %v, want [I2]", got)
+	}
+
+	ix.Remove("I2")
+	ix.Put("I3", "gold purchase")
+	if got := hitIds(ix.Search("refund", nil, 0)); !slices.Equal(got, []string{"I1"}) {
+		t.Errorf("Search after Remove and Put = %v, want [I1]", got)
+	}
+	if ix.Len() != 2 {
+		t.Errorf("Len = %d, want 2", ix.Len())
+	}
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are too common to tell documents apart, they are left out of the index and of queries
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "i": true, "in": true, "is": true, "it": true, "its": true,
+	"my": true, "of": true, "on": true, "or": true, "so": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true,
+}
+
+// Tokenize splits text into lowercased, stemmed terms on every character that is neither a letter nor a digit,
+// dropping stop words
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+// Stem strips the common English inflections so that "failed", "failing" and "fails" all index as "fail". It is a
+// light stemmer in the manner of Porter's first step: a plural s goes first, then ed or ing when what is left holds a
+// vowel, so that "strings" meets "string" without "thing" losing its ing. A trailing e goes last, which is what makes
+// "use", "uses", "used" and "using" all meet at "us".
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	word = stripInflection(stripPlural(word))
+	if len(word) >= 3 && strings.HasSuffix(word, "e") {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+// stripPlural takes the s off words of more than three letters, "classes" and "class" keep their double s and
+// "replies" turns back into "reply"
+func stripPlural(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	if stem, ok := strings.CutSuffix(word, "sses"); ok {
+		return stem + "ss"
+	}
+	if stem, ok := strings.CutSuffix(word, "ies"); ok && len(stem) >= 2 {
+		return stem + "y"
+	}
+	if strings.HasSuffix(word, "ss") {
+		return word
+	}
+	return strings.TrimSuffix(word, "s")
+}
+
+// stripInflection takes ed or ing off the word when the stem left holds a vowel, so "need" and "bring" are kept whole
+func stripInflection(word string) string {
+	if stem, ok := strings.CutSuffix(word, "eed"); ok {
+		if hasVowel(stem) {
+			return stem + "ee"
+		}
+		return word
+	}
+	if stem, ok := strings.CutSuffix(word, "ied"); ok && len(stem) >= 2 {
+		return stem + "y"
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if stem, ok := strings.CutSuffix(word, suffix); ok && hasVowel(stem) {
+			return undouble(stem)
+		}
+	}
+	return word
+}
+
+// hasVowel reports whether the stem holds a vowel, a y counts as one after the first letter like in "try"
+func hasVowel(stem string) bool {
+	for i, r := range stem {
+		if strings.ContainsRune("aeiou", r) || (r == 'y' && i > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// undouble turns the doubled consonant left behind by "ing" and "ed" back into one, "debitted" stems as "debit"
+func undouble(stem string) string {
+	n := len(stem)
+	if n >= 4 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiouslz", rune(stem[n-1])) {
+		return stem[:n-1]
+	}
+	return stem
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"My payment FAILED, but money is debited!", []string{"payment", "fail", "money", "debit"}},
+		{"txn-42/refund_pending", []string{"txn", "42", "refund", "pend"}},
+		{"the and of", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestStemMergesInflections(t *testing.T) {
+	families := [][]string{
+		{"use", "uses", "used", "using"},
+		{"fail", "fails", "failed", "failing"},
+		{"charge", "charges", "charged", "charging"},
+		{"debit", "debits", "debited", "debitted"},
+		{"stop", "stops", "stopped", "stopping"},
+		{"reply", "replies", "replied", "replying"},
+		{"try", "tries", "tried", "trying"},
+		{"need", "needs", "needed", "needing"},
+		{"agree", "agrees", "agreed"},
+		{"process", "processes", "processed", "processing"},
+		{"mandate", "mandates", "mandated"},
+		{"class", "classes"},
+		{"string", "strings"},
+		{"box", "boxes"},
+	}
+	for _, family := range families {
+		want := Stem(family[0])
+		for _, word := range family[1:] {
+			if got := Stem(word); got != want {
+				t.Errorf("Stem(%q) = %q, want %q like Stem(%q)", word, got, want, family[0])
+			}
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct{ word, want string }{
+		{"failed", "fail"},
+		{"used", "us"},
+		{"replies", "reply"},
+		{"classes", "class"},
+		{"thing", "thing"}, // no vowel is left before the ing
+		{"things", "thing"},
+		{"bring", "bring"},
+		{"red", "red"},
+		{"need", "need"},
+		{"fee", "fe"},
+		{"feed", "feed"},
+		{"bus", "bus"},
+		{"buses", "bus"},
+		{"id", "id"},
+		{"2024", "2024"},
+	}
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -4,62 +4,130 @@ import (
 	"container/heap"
 	"fmt"
 	m "iss/internal/models"
+	"iss/internal/repository"
 	"sync"
-	"sync/atomic"
 )
 
 type AgentService struct {
-	agents                     map[string]*m.Agent
-	AvailableAgentsByExpertise map[m.IssueType]map[string]*m.Agent
+	repo                       repository.AgentRepository
+	AvailableAgentsByExpertise ExpertiseIndex
 	busyAgentHeap              *AgentHeap
-	idCounter                  int32
+	ids                        IDGenerator
 	mu                         sync.RWMutex
 }
 
-func NewAgentService() *AgentService {
-	return &AgentService{
-		agents:                     make(map[string]*m.Agent),
-		AvailableAgentsByExpertise: make(map[m.IssueType]map[string]*m.Agent),
+// NewAgentService keeps agents in memory when no repository is given, otherwise it rebuilds
+// the availability indexes and the busy heap from the agents already stored in the repository
+func NewAgentService(repo repository.AgentRepository) *AgentService {
+	if repo == nil {
+		repo = repository.NewMemoryAgentRepository()
+	}
+	as := &AgentService{
+		repo:                       repo,
+		AvailableAgentsByExpertise: make(ExpertiseIndex),
 		busyAgentHeap:              InitializeHeap(),
+		ids:                        NewSequenceGenerator(),
+	}
+	for _, agent := range repo.List() {
+		as.ids.Observe(agent.Id)
+		as.index(agent)
 	}
+	return as
 }
 
-func (as *AgentService) AddAgent(email, name string, expertise map[m.IssueType]bool) (string, error) {
-	as.mu.Lock()
-	defer as.mu.Unlock()
+func (as *AgentService) AddAgent(email, name string, expertise map[m.IssueType]m.Skill, capacity int) (string, error) {
+	agent, err := as.NewAgent(email, name, expertise, capacity)
+	if err != nil {
+		return "", err
+	}
+	if err := as.RegisterAgent(agent); err != nil {
+		return "", err
+	}
+	return agent.Id, nil
+}
 
-	id := fmt.Sprintf("A%d", atomic.AddInt32(&as.idCounter, 1))
-	agent, err := m.NewAgent(id, email, name, expertise)
+// NewAgent validates and builds an agent with the next ID of the generator without registering it
+func (as *AgentService) NewAgent(email, name string, expertise map[m.IssueType]m.Skill, capacity int) (*m.Agent, error) {
+	as.mu.RLock()
+	ids := as.ids
+	as.mu.RUnlock()
+	agent, err := m.NewAgent(ids.NewId(AgentIdPrefix), name, email, expertise, capacity)
 	if err != nil {
 		fmt.Println("error occurred", err)
-		return "", err
+		return nil, err
 	}
-	as.agents[id] = agent
-	for expertise, _ := range agent.Expertise {
-		if _, ok := as.AvailableAgentsByExpertise[expertise]; ok {
-			as.AvailableAgentsByExpertise[expertise][agent.Id] = agent
-		} else {
-			as.AvailableAgentsByExpertise[expertise] = map[string]*m.Agent{
-				agent.Id: agent,
-			}
+	return agent, nil
+}
+
+func (as *AgentService) RegisterAgent(agent *m.Agent) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if err := as.repo.Save(agent); err != nil {
+		return fmt.Errorf("error occurred while saving agent %w", err)
+	}
+	as.ids.Observe(agent.Id)
+	as.index(agent)
+	return nil
+}
+
+// SetIDGenerator replaces the sequence agent IDs are taken from, the IDs of the stored agents are observed first
+func (as *AgentService) SetIDGenerator(ids IDGenerator) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for _, agent := range as.repo.List() {
+		ids.Observe(agent.Id)
+	}
+	as.ids = ids
+}
+
+// index places the agent in AvailableAgentsByExpertise while it is below capacity, otherwise in the busy heap.
+// Agents that are deactivated or not Online are kept out of both so that no strategy routes work to them.
+func (as *AgentService) index(agent *m.Agent) {
+	if !agent.AcceptsWork() {
+		if agent.HeapIndex >= 0 && agent.HeapIndex < as.busyAgentHeap.Len() {
+			heap.Remove(as.busyAgentHeap, agent.HeapIndex)
+		}
+		as.AvailableAgentsByExpertise.Remove(agent)
+		return
+	}
+
+	if agent.IsAvailable() {
+		if agent.HeapIndex >= 0 && agent.HeapIndex < as.busyAgentHeap.Len() {
+			heap.Remove(as.busyAgentHeap, agent.HeapIndex)
 		}
+		as.AvailableAgentsByExpertise.Add(agent)
+		return
+	}
+
+	as.AvailableAgentsByExpertise.Remove(agent)
+	if agent.HeapIndex >= 0 && agent.HeapIndex < as.busyAgentHeap.Len() {
+		heap.Fix(as.busyAgentHeap, agent.HeapIndex)
+	} else {
+		heap.Push(as.busyAgentHeap, agent)
 	}
-	return id, nil
 }
 
 func (as *AgentService) GetAgent(id string) *m.Agent {
 	as.mu.RLock()
 	defer as.mu.RUnlock()
-	return as.agents[id]
+	return as.repo.Get(id)
 }
 
+// GetAgents returns the agents on the roster, removed agents are left out but GetAgent still finds them
 func (as *AgentService) GetAgents() map[string]*m.Agent {
 	as.mu.RLock()
 	defer as.mu.RUnlock()
-	return as.agents
+	agents := make(map[string]*m.Agent)
+	for _, agent := range as.repo.List() {
+		if !agent.IsRemoved() {
+			agents[agent.Id] = agent
+		}
+	}
+	return agents
 }
 
-func (as *AgentService) GetAvailableAgentsByExpertise() map[m.IssueType]map[string]*m.Agent {
+func (as *AgentService) GetAvailableAgentsByExpertise() ExpertiseIndex {
 	as.mu.RLock()
 	defer as.mu.RUnlock()
 	return as.AvailableAgentsByExpertise
@@ -75,66 +143,169 @@ func (as *AgentService) AssignIssue(agent *m.Agent, issue *m.Issue) (bool, error
 	as.mu.Lock()
 	defer as.mu.Unlock()
 
+	if agent.IsDeactivated() {
+		return false, fmt.Errorf("cannot assign %s to %s, %w", issue.Id, agent.Id, ErrAgentDeactivated)
+	}
+
 	waitListed := true
 	if agent.IsAvailable() {
 		err := agent.AssignIssue(issue)
 		if err != nil {
 			return false, fmt.Errorf("error occurred %w", err)
 		}
-		for expertise, _ := range agent.GetExpertise() {
-			delete(as.AvailableAgentsByExpertise[expertise], agent.Id)
-		}
 		waitListed = false
 	} else {
 		agent.AddToPendingIssues(issue)
 	}
 
-	heap.Push(as.busyAgentHeap, agent)
+	as.index(agent)
+	if err := as.repo.Save(agent); err != nil {
+		return waitListed, fmt.Errorf("error occurred while saving agent %w", err)
+	}
 	return waitListed, nil
 }
 
-func (as *AgentService) GetWorkHistory() map[string][]string {
+type WorkHistory struct {
+	ResolvedIssues []string       `json:"resolved_issues"`
+	ReopenedIssues map[string]int `json:"reopened_issues"`
+}
+
+func (as *AgentService) GetWorkHistory() map[string]WorkHistory {
 	as.mu.RLock()
 	defer as.mu.RUnlock()
 
-	history := make(map[string][]string)
-	for _, agent := range as.agents {
-		resolved := agent.GetResolvedIssues()
-		issueIDs := make([]string, 0, len(resolved))
-		for id := range resolved {
+	history := make(map[string]WorkHistory)
+	for _, agent := range as.repo.List() {
+		// the snapshot owns its maps, the agent's own keep changing once its lock is released
+		agent = agent.Snapshot()
+		issueIDs := make([]string, 0, len(agent.ResolvedIssues))
+		for id := range agent.ResolvedIssues {
 			issueIDs = append(issueIDs, id)
 		}
-		history[agent.Id] = issueIDs
+		history[agent.Id] = WorkHistory{ResolvedIssues: issueIDs, ReopenedIssues: agent.ReopenedIssues}
 	}
 	return history
 }
 
-func (as *AgentService) ResolveIssue(agentId, resolution string) (*m.Issue, error) {
+// PendingWith returns the agent whose pending queue holds the issue, nil when it is not queued anywhere
+func (as *AgentService) PendingWith(issueId string) *m.Agent {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	for _, agent := range as.repo.List() {
+		for _, issue := range agent.GetPendingIssues() {
+			if issue.Id == issueId {
+				return agent
+			}
+		}
+	}
+	return nil
+}
+
+// RemovePendingIssue takes the issue out of the pending queue of whichever agent holds it
+func (as *AgentService) RemovePendingIssue(issueId string) (*m.Agent, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	for _, agent := range as.repo.List() {
+		if agent.RemovePendingIssue(issueId) {
+			as.index(agent)
+			if err := as.repo.Save(agent); err != nil {
+				return agent, fmt.Errorf("error occurred while saving agent %w", err)
+			}
+			return agent, nil
+		}
+	}
+	return nil, fmt.Errorf("issue %s is not pending with any agent", issueId)
+}
+
+func (as *AgentService) ReopenIssue(agentId, issueId string) error {
 	as.mu.Lock()
 	defer as.mu.Unlock()
 
-	if agent, ok := as.agents[agentId]; ok {
-		newIssueAssigned, err := agent.ResolveIssue(resolution)
+	agent := as.repo.Get(agentId)
+	if agent == nil {
+		return ErrAgentNotFound
+	}
+	if err := agent.ReopenIssue(issueId); err != nil {
+		return err
+	}
+	return as.repo.Save(agent)
+}
+
+func (as *AgentService) SetPresence(agentId string, presence m.Presence) error {
+	return as.update(agentId, func(agent *m.Agent) { agent.SetPresence(presence) })
+}
+
+// Deactivate takes the agent out of the indexes for good, its work has to be handed over separately
+func (as *AgentService) Deactivate(agentId string, at int64) error {
+	return as.update(agentId, func(agent *m.Agent) { agent.Deactivate(at) })
+}
+
+func (as *AgentService) Activate(agentId string) error {
+	return as.update(agentId, (*m.Agent).Activate)
+}
+
+// Remove drops a deactivated agent from the roster while keeping its history
+func (as *AgentService) Remove(agentId string) error {
+	return as.update(agentId, (*m.Agent).Remove)
+}
+
+// ReleaseIssue takes an active issue away from the agent without resolving it and returns the pending issue
+// promoted in its place, if any
+func (as *AgentService) ReleaseIssue(agentId, issueId string) (*m.Issue, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	agent := as.repo.Get(agentId)
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+	promoted, err := agent.ReleaseIssue(issueId)
+	if err != nil {
+		return nil, err
+	}
+	as.index(agent)
+	if err := as.repo.Save(agent); err != nil {
+		return promoted, fmt.Errorf("error occurred while saving agent %w", err)
+	}
+	return promoted, nil
+}
+
+// update applies the change to the agent and re-indexes and saves it
+func (as *AgentService) update(agentId string, change func(agent *m.Agent)) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	agent := as.repo.Get(agentId)
+	if agent == nil {
+		return ErrAgentNotFound
+	}
+	change(agent)
+	as.index(agent)
+	return as.repo.Save(agent)
+}
+
+func (as *AgentService) SetShifts(agentId string, shifts []m.Shift) error {
+	return as.update(agentId, func(agent *m.Agent) { agent.SetShifts(shifts) })
+}
+
+func (as *AgentService) ResolveIssue(agentId, issueId, resolution string) (*m.Issue, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if agent := as.repo.Get(agentId); agent != nil {
+		newIssueAssigned, err := agent.ResolveIssue(issueId, resolution)
 		if err != nil {
 			return nil, err
 		}
-		if newIssueAssigned != nil {
-			// Agent is still busy, update its position in the heap
-			if agent.HeapIndex >= 0 && agent.HeapIndex < as.busyAgentHeap.Len() {
-				heap.Fix(as.busyAgentHeap, agent.HeapIndex)
-			} else {
-				// Shouldn’t happen if agent was busy, but push as fallback
-				heap.Push(as.busyAgentHeap, agent)
-			}
-		} else {
-			for expertise, _ := range agent.GetExpertise() {
-				as.AvailableAgentsByExpertise[expertise] = map[string]*m.Agent{
-					agentId: agent,
-				}
-			}
+		// a promoted pending issue keeps the agent at capacity with a shorter queue, otherwise it has room again
+		as.index(agent)
+		if err := as.repo.Save(agent); err != nil {
+			return newIssueAssigned, fmt.Errorf("error occurred while saving agent %w", err)
 		}
 		return newIssueAssigned, nil
 	} else {
-		return nil, fmt.Errorf("agent not found")
+		return nil, ErrAgentNotFound
 	}
 }
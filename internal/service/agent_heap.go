@@ -7,8 +7,18 @@ import (
 
 type AgentHeap []*models.Agent
 
-func (h AgentHeap) Len() int           { return len(h) }
-func (h AgentHeap) Less(i, j int) bool { return len(h[i].PendingIssues) < len(h[j].PendingIssues) }
+func (h AgentHeap) Len() int { return len(h) }
+
+// Less orders agents by utilization, so that an agent with a larger capacity absorbs proportionally
+// more of the queue, ties go to the lowest agent ID
+func (h AgentHeap) Less(i, j int) bool {
+	ui, uj := h[i].Utilization(), h[j].Utilization()
+	if ui != uj {
+		return ui < uj
+	}
+	return agentIdLess(h[i].Id, h[j].Id)
+}
+
 func (h AgentHeap) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
 	h[i].HeapIndex, h[j].HeapIndex = i, j
@@ -29,6 +39,14 @@ func (h *AgentHeap) Pop() interface{} {
 	return agent
 }
 
+// Peek returns the least utilized agent without removing it
+func (h AgentHeap) Peek() *models.Agent {
+	if len(h) == 0 {
+		return nil
+	}
+	return h[0]
+}
+
 func InitializeHeap() *AgentHeap {
 	h := &AgentHeap{}
 	heap.Init(h)
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+)
+
+// DeactivateAgent stops routing work to the agent and hands its pending issues, then its active issues, to the
+// agents chosen by the assignment strategy. Issues nobody can take are parked in the unassigned pool. The agent
+// keeps its resolved issues for reporting. The returned map holds the new agent of every handed over issue,
+// empty for parked ones.
+func (rs *ResolutionService) DeactivateAgent(agentId string) (map[string]string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil || agent.IsRemoved() {
+		return nil, ErrAgentNotFound
+	}
+	if agent.IsDeactivated() {
+		return nil, fmt.Errorf("cannot deactivate %s, %w", agentId, ErrAgentDeactivated)
+	}
+	return rs.deactivate(agent)
+}
+
+// deactivate expects rs.mutex to be held
+func (rs *ResolutionService) deactivate(agent *models.Agent) (map[string]string, error) {
+	moved := make(map[string]string)
+	if err := rs.commit(&events.Event{Type: events.AgentDeactivated, AgentId: agent.Id}); err != nil {
+		return moved, err
+	}
+	fmt.Printf("Agent %s has been deactivated \n", agent.Id)
+	// the queue goes first, otherwise every active issue handed over would promote a pending one in its place
+	reason := fmt.Sprintf("agent %s deactivated", agent.Id)
+	if err := rs.handOver(agent, events.PendingIssueMoved, agent.GetPendingIssues(), reason, moved); err != nil {
+		return moved, err
+	}
+	return moved, rs.handOver(agent, events.IssueHandedOver, agent.GetActiveIssues(), reason, moved)
+}
+
+// ActivateAgent lets a deactivated agent take work again, the parked issues are then routed again
+func (rs *ResolutionService) ActivateAgent(agentId string) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil || agent.IsRemoved() {
+		return ErrAgentNotFound
+	}
+	if !agent.IsDeactivated() {
+		return nil
+	}
+	if err := rs.commit(&events.Event{Type: events.AgentActivated, AgentId: agentId}); err != nil {
+		return err
+	}
+	fmt.Printf("Agent %s has been activated \n", agentId)
+	return rs.drainUnassigned()
+}
+
+// RemoveAgent deactivates the agent if it still is active and drops it from the roster. The agent stays
+// reachable through GetAgent and the work history so that its resolved issues can still be reported on.
+func (rs *ResolutionService) RemoveAgent(agentId string) (map[string]string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil || agent.IsRemoved() {
+		return nil, ErrAgentNotFound
+	}
+	moved := make(map[string]string)
+	if !agent.IsDeactivated() {
+		var err error
+		if moved, err = rs.deactivate(agent); err != nil {
+			return moved, err
+		}
+	}
+	if err := rs.commit(&events.Event{Type: events.AgentRemoved, AgentId: agentId}); err != nil {
+		return moved, err
+	}
+	fmt.Printf("Agent %s has been removed \n", agentId)
+	return moved, nil
+}
@@ -1,56 +1,427 @@
 package service
 
 import (
-	"container/heap"
+	"fmt"
 	"iss/internal/models"
+	"sort"
+	"strings"
+	"sync"
 )
 
+// AssignmentStrategy picks the agent an issue goes to, tier is the tier of the customer who raised it
 type AssignmentStrategy interface {
-	Assign(issue *models.Issue, availableAgentsMapByExpertise map[models.IssueType]map[string]*models.Agent, busyAgentHeap *AgentHeap) *models.Agent
+	Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent
+}
+
+// UnassignedPooler is implemented by strategies that would rather park an issue in the unassigned pool
+// than see it fail assignment when Assign finds no eligible agent
+type UnassignedPooler interface {
+	PoolUnassigned() bool
+}
+
+// Expediter is implemented by strategies that let some issues jump ahead of the pending issues of the agent
+// they are queued with
+type Expediter interface {
+	Expedites(issue *models.Issue, tier models.CustomerTier) bool
 }
 
 type AssignmentStrategies int
 
 const (
 	FreeAgentFirst AssignmentStrategies = iota
+	LeastLoaded
+	WeightedLeastLoaded
+	StrictExpertise
+	RoundRobin
+	ProficiencyBased
+	TierAware
 )
 
+func (as AssignmentStrategies) String() string {
+	switch as {
+	case LeastLoaded:
+		return "least-loaded"
+	case WeightedLeastLoaded:
+		return "weighted-least-loaded"
+	case StrictExpertise:
+		return "strict-expertise"
+	case RoundRobin:
+		return "round-robin"
+	case ProficiencyBased:
+		return "proficiency"
+	case TierAware:
+		return "tier-aware"
+	default:
+		return "free-agent-first"
+	}
+}
+
+func ParseAssignmentStrategy(s string) (AssignmentStrategies, error) {
+	for _, as := range []AssignmentStrategies{FreeAgentFirst, LeastLoaded, WeightedLeastLoaded, StrictExpertise, RoundRobin, ProficiencyBased, TierAware} {
+		if strings.EqualFold(as.String(), s) {
+			return as, nil
+		}
+	}
+	return FreeAgentFirst, fmt.Errorf("unknown assignment strategy %q", s)
+}
+
 type FreeAgentFirstStrategy struct{}
 
-func (s *FreeAgentFirstStrategy) Assign(issue *models.Issue, availableAgentsMapByExpertise map[models.IssueType]map[string]*models.Agent, busyAgentHeap *AgentHeap) *models.Agent {
-	var minQueueAgent *models.Agent
+func (s *FreeAgentFirstStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
 	expertise := issue.Type
 
 	// if an agent with desired expertise is available
-	if len(availableAgentsMapByExpertise[expertise]) > 0 {
-		for _, agent := range availableAgentsMapByExpertise[expertise] {
-			return agent
-		}
+	if agents := availableAgentsByExpertise.Agents(expertise); len(agents) > 0 {
+		return agents[0]
 	}
 
 	// check if any agent with different expertise
-	for _, agentMap := range availableAgentsMapByExpertise {
-		if len(agentMap) > 0 {
-			for _, agent := range agentMap {
-				return agent
-			}
+	for _, other := range availableAgentsByExpertise.Types() {
+		if agents := availableAgentsByExpertise.Agents(other); len(agents) > 0 {
+			return agents[0]
 		}
 	}
 
-	// if all the agents are busy
-	if busyAgentHeap.Len() > 0 {
-		minQueueAgent = heap.Pop(busyAgentHeap).(*models.Agent)
-	}
-
-	return minQueueAgent
+	// if all the agents are busy, strategies only pick an agent and leave the heap to the AgentService
+	return busyAgentHeap.Peek()
 }
 
 func NewFreeAgentFirstStrategy() *FreeAgentFirstStrategy {
 	return &FreeAgentFirstStrategy{}
 }
 
+// LeastLoadedStrategy picks the agent with the smallest workload among the agents holding the issue's
+// expertise, counting every active and pending issue. With weightByPriority set, urgent issues
+// weigh more than routine ones. Ties go to the lowest agent ID so that assignments are reproducible.
+// When no agent has the expertise the issue is parked in the unassigned pool until an expert joins.
+type LeastLoadedStrategy struct {
+	weightByPriority bool
+}
+
+func NewLeastLoadedStrategy(weightByPriority bool) *LeastLoadedStrategy {
+	return &LeastLoadedStrategy{weightByPriority: weightByPriority}
+}
+
+func (s *LeastLoadedStrategy) PoolUnassigned() bool {
+	return true
+}
+
+func (s *LeastLoadedStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
+	agents := allAgents(availableAgentsByExpertise, busyAgentHeap)
+	experts := make([]*models.Agent, 0, len(agents))
+	for _, agent := range agents {
+		if agent.HasExpertise(issue.Type) {
+			experts = append(experts, agent)
+		}
+	}
+	var best *models.Agent
+	bestLoad := 0
+	for _, agent := range experts {
+		load := s.workload(agent)
+		if best == nil || load < bestLoad || (load == bestLoad && agentIdLess(agent.Id, best.Id)) {
+			best, bestLoad = agent, load
+		}
+	}
+	return best
+}
+
+func (s *LeastLoadedStrategy) workload(agent *models.Agent) int {
+	load := 0
+	for _, active := range agent.GetActiveIssues() {
+		load += s.weight(active)
+	}
+	for _, pending := range agent.GetPendingIssues() {
+		load += s.weight(pending)
+	}
+	return load
+}
+
+// weight counts a P3 issue as 1 and every step up in priority as one more
+func (s *LeastLoadedStrategy) weight(issue *models.Issue) int {
+	if !s.weightByPriority {
+		return 1
+	}
+	return int(issue.Priority-models.P3) + 1
+}
+
+// StrictExpertiseStrategy only ever hands an issue to an agent holding its expertise. A free expert is
+// preferred, otherwise the expert with the shortest queue below maxPending is waitlisted. When every expert
+// is saturated, or there is none, the issue is parked in the unassigned pool until an expert frees up.
+type StrictExpertiseStrategy struct {
+	maxPending int // 0 means an expert's queue is never saturated
+}
+
+func NewStrictExpertiseStrategy(maxPending int) *StrictExpertiseStrategy {
+	return &StrictExpertiseStrategy{maxPending: maxPending}
+}
+
+func (s *StrictExpertiseStrategy) PoolUnassigned() bool {
+	return true
+}
+
+func (s *StrictExpertiseStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
+	if agents := availableAgentsByExpertise.Agents(issue.Type); len(agents) > 0 {
+		return agents[0]
+	}
+
+	var best *models.Agent
+	bestPending := 0
+	for _, agent := range *busyAgentHeap {
+		if !agent.HasExpertise(issue.Type) || !agent.HasCapacityFor(issue.Type) {
+			continue
+		}
+		pending := len(agent.GetPendingIssues())
+		if s.maxPending > 0 && pending >= s.maxPending {
+			continue
+		}
+		if best == nil || pending < bestPending || (pending == bestPending && agentIdLess(agent.Id, best.Id)) {
+			best, bestPending = agent, pending
+		}
+	}
+	return best
+}
+
+// RoundRobinStrategy spreads issues evenly over the agents holding the issue's expertise. Every IssueType
+// has its own cursor over those agents ordered by CreatedAt and ID, and each assignment goes to the next
+// free agent after the cursor. When all of them are busy the issue is waitlisted on the next agent in turn.
+type RoundRobinStrategy struct {
+	cursors map[models.IssueType]string // ID of the agent that received the last issue of the type
+	mu      sync.Mutex
+}
+
+func NewRoundRobinStrategy() *RoundRobinStrategy {
+	return &RoundRobinStrategy{
+		cursors: make(map[models.IssueType]string),
+	}
+}
+
+func (s *RoundRobinStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotation := make([]*models.Agent, 0)
+	for _, agent := range allAgents(availableAgentsByExpertise, busyAgentHeap) {
+		if agent.HasExpertise(issue.Type) {
+			rotation = append(rotation, agent)
+		}
+	}
+	if len(rotation) == 0 {
+		return nil
+	}
+	sort.Slice(rotation, func(i, j int) bool {
+		if rotation[i].CreatedAt != rotation[j].CreatedAt {
+			return rotation[i].CreatedAt < rotation[j].CreatedAt
+		}
+		return agentIdLess(rotation[i].Id, rotation[j].Id)
+	})
+
+	// start right after the agent that got the previous issue, wrapping around
+	start := 0
+	for i, agent := range rotation {
+		if agent.Id == s.cursors[issue.Type] {
+			start = i + 1
+			break
+		}
+	}
+	chosen := rotation[start%len(rotation)]
+	for i := range rotation {
+		if agent := rotation[(start+i)%len(rotation)]; agent.IsAvailable() {
+			chosen = agent
+			break
+		}
+	}
+	s.cursors[issue.Type] = chosen.Id
+	return chosen
+}
+
+// ProficiencyStrategy routes by skill level: urgent issues (P0 and P1) go to the most proficient free
+// agent holding the expertise, routine ones to the least proficient, keeping seniors free for what needs
+// them. When no expert is free the issue is waitlisted on the expert with the shortest queue whose level
+// suits the priority best, skipping experts at the MaxConcurrent of their skill.
+type ProficiencyStrategy struct{}
+
+func NewProficiencyStrategy() *ProficiencyStrategy {
+	return &ProficiencyStrategy{}
+}
+
+func (s *ProficiencyStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
+	urgent := !models.P1.MoreUrgentThan(issue.Priority)
+	// suitsBetter reports whether agent a suits the issue better than agent b, ties go to the lowest ID
+	suitsBetter := func(a, b *models.Agent) bool {
+		levelA, levelB := a.ProficiencyIn(issue.Type), b.ProficiencyIn(issue.Type)
+		if levelA != levelB {
+			return (levelA > levelB) == urgent
+		}
+		return agentIdLess(a.Id, b.Id)
+	}
+
+	var best *models.Agent
+	for _, agent := range availableAgentsByExpertise.Agents(issue.Type) {
+		if best == nil || suitsBetter(agent, best) {
+			best = agent
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	bestPending := 0
+	for _, agent := range *busyAgentHeap {
+		if !agent.HasExpertise(issue.Type) || !agent.HasCapacityFor(issue.Type) {
+			continue
+		}
+		pending := len(agent.GetPendingIssues())
+		if best == nil || pending < bestPending || (pending == bestPending && suitsBetter(agent, best)) {
+			best, bestPending = agent, pending
+		}
+	}
+	return best
+}
+
+// TierAwareStrategy gets VIP customers faster handling. Their issues go to the most proficient free agent of the
+// VIP pool holding the expertise. Once no pool agent is free the VIP pool is exhausted and the issue goes to the agent
+// picked by the fallback strategy if that agent is free, otherwise it is queued with the pool agent with the shortest
+// queue, expedited ahead of every issue that is not. Without a configured pool the experts with the highest
+// proficiency in the issue type make up the pool. Issues of the other tiers are routed by the fallback alone.
+type TierAwareStrategy struct {
+	fallback AssignmentStrategy
+	pool     map[string]bool // IDs of the agents dedicated to VIP customers
+}
+
+func NewTierAwareStrategy(fallback AssignmentStrategy, pool ...string) *TierAwareStrategy {
+	s := &TierAwareStrategy{fallback: fallback, pool: make(map[string]bool)}
+	for _, id := range pool {
+		s.pool[id] = true
+	}
+	return s
+}
+
+func (s *TierAwareStrategy) Expedites(issue *models.Issue, tier models.CustomerTier) bool {
+	return tier == models.VIP
+}
+
+// PoolUnassigned follows the fallback strategy
+func (s *TierAwareStrategy) PoolUnassigned() bool {
+	pooler, ok := s.fallback.(UnassignedPooler)
+	return ok && pooler.PoolUnassigned()
+}
+
+func (s *TierAwareStrategy) Assign(issue *models.Issue, tier models.CustomerTier, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) *models.Agent {
+	if tier != models.VIP {
+		return s.fallback.Assign(issue, tier, availableAgentsByExpertise, busyAgentHeap)
+	}
+	// moreProficient reports whether agent a is more proficient in the issue type than agent b, ties go to the lowest ID
+	moreProficient := func(a, b *models.Agent) bool {
+		levelA, levelB := a.ProficiencyIn(issue.Type), b.ProficiencyIn(issue.Type)
+		if levelA != levelB {
+			return levelA > levelB
+		}
+		return agentIdLess(a.Id, b.Id)
+	}
+
+	pool := s.vipPool(issue.Type, availableAgentsByExpertise, busyAgentHeap)
+	var best *models.Agent
+	for _, agent := range pool {
+		if agent.IsAvailable() && (best == nil || moreProficient(agent, best)) {
+			best = agent
+		}
+	}
+	if best != nil {
+		return best
+	}
+	fallback := s.fallback.Assign(issue, tier, availableAgentsByExpertise, busyAgentHeap)
+	if fallback != nil && fallback.IsAvailable() {
+		return fallback
+	}
+
+	bestPending := 0
+	for _, agent := range pool {
+		if !agent.HasCapacityFor(issue.Type) {
+			continue
+		}
+		pending := len(agent.GetPendingIssues())
+		if best == nil || pending < bestPending || (pending == bestPending && moreProficient(agent, best)) {
+			best, bestPending = agent, pending
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+// vipPool returns the agents holding the expertise that VIP issues of the type go to first
+func (s *TierAwareStrategy) vipPool(issueType models.IssueType, availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) []*models.Agent {
+	experts := make([]*models.Agent, 0)
+	top := models.NoProficiency
+	for _, agent := range allAgents(availableAgentsByExpertise, busyAgentHeap) {
+		if !agent.HasExpertise(issueType) {
+			continue
+		}
+		if len(s.pool) > 0 && !s.pool[agent.Id] {
+			continue
+		}
+		experts = append(experts, agent)
+		top = max(top, agent.ProficiencyIn(issueType))
+	}
+	if len(s.pool) > 0 {
+		return experts
+	}
+	pool := make([]*models.Agent, 0, len(experts))
+	for _, agent := range experts {
+		if agent.ProficiencyIn(issueType) == top {
+			pool = append(pool, agent)
+		}
+	}
+	return pool
+}
+
+// agentIdLess orders generated agent IDs numerically, so that A2 comes before A10
+func agentIdLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// allAgents collects every known agent once, the available ones from the expertise index and the busy ones from the heap
+func allAgents(availableAgentsByExpertise ExpertiseIndex, busyAgentHeap *AgentHeap) []*models.Agent {
+	seen := make(map[string]bool)
+	agents := make([]*models.Agent, 0)
+	for _, issueType := range availableAgentsByExpertise.Types() {
+		for _, agent := range availableAgentsByExpertise.Agents(issueType) {
+			if !seen[agent.Id] {
+				seen[agent.Id] = true
+				agents = append(agents, agent)
+			}
+		}
+	}
+	for _, agent := range *busyAgentHeap {
+		if !seen[agent.Id] {
+			seen[agent.Id] = true
+			agents = append(agents, agent)
+		}
+	}
+	return agents
+}
+
+// defaultMaxPending is the queue length at which StrictExpertise considers an agent saturated
+const defaultMaxPending = 5
+
 func GetAssignmentStrategy(as AssignmentStrategies) AssignmentStrategy {
 	switch as {
+	case LeastLoaded:
+		return NewLeastLoadedStrategy(false)
+	case WeightedLeastLoaded:
+		return NewLeastLoadedStrategy(true)
+	case StrictExpertise:
+		return NewStrictExpertiseStrategy(defaultMaxPending)
+	case RoundRobin:
+		return NewRoundRobinStrategy()
+	case ProficiencyBased:
+		return NewProficiencyStrategy()
+	case TierAware:
+		return NewTierAwareStrategy(NewProficiencyStrategy())
 	default:
 		return NewFreeAgentFirstStrategy()
 	}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	m "iss/internal/models"
+	"iss/internal/repository"
+	"strings"
+	"sync"
+)
+
+// CustomerIdPrefix tells customer IDs apart from issue and agent IDs
+const CustomerIdPrefix = "C"
+
+type CustomerService struct {
+	repo    repository.CustomerRepository
+	byEmail map[string]string // lowercased email -> customer ID
+	ids     IDGenerator
+	mu      sync.RWMutex
+}
+
+// NewCustomerService keeps customers in memory when no repository is given and indexes the emails of the
+// customers already stored
+func NewCustomerService(repo repository.CustomerRepository) *CustomerService {
+	if repo == nil {
+		repo = repository.NewMemoryCustomerRepository()
+	}
+	cs := &CustomerService{
+		repo:    repo,
+		byEmail: make(map[string]string),
+		ids:     NewSequenceGenerator(),
+	}
+	for _, customer := range repo.List() {
+		cs.ids.Observe(customer.Id)
+		for _, email := range customer.GetEmails() {
+			cs.byEmail[email] = customer.Id
+		}
+	}
+	return cs
+}
+
+// SetIDGenerator replaces the sequence customer IDs are taken from, the IDs of the stored customers are observed first
+func (cs *CustomerService) SetIDGenerator(ids IDGenerator) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, customer := range cs.repo.List() {
+		ids.Observe(customer.Id)
+	}
+	cs.ids = ids
+}
+
+// NewCustomer validates and builds a customer with the next ID of the generator without registering it, none of
+// its emails may belong to another customer
+func (cs *CustomerService) NewCustomer(emails []string, phone string, tier m.CustomerTier, locale string, openIssueLimit int) (*m.Customer, error) {
+	customer, err := m.NewCustomer("", emails, phone, tier, locale, openIssueLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.checkEmails("", customer.Emails); err != nil {
+		return nil, err
+	}
+	cs.mu.RLock()
+	customer.Id = cs.ids.NewId(CustomerIdPrefix)
+	cs.mu.RUnlock()
+	return customer, nil
+}
+
+// ValidateUpdate checks the new details of the customer the way UpdateCustomer would and returns them normalized
+func (cs *CustomerService) ValidateUpdate(id string, emails []string, phone string, tier m.CustomerTier, locale string, openIssueLimit int) (*m.Customer, error) {
+	if cs.GetCustomer(id) == nil {
+		return nil, ErrCustomerNotFound
+	}
+	updated, err := m.NewCustomer(id, emails, phone, tier, locale, openIssueLimit)
+	if err != nil {
+		return nil, err
+	}
+	return updated, cs.checkEmails(id, updated.Emails)
+}
+
+// checkEmails fails when any of the emails belongs to a customer other than id
+func (cs *CustomerService) checkEmails(id string, emails []string) error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	for _, email := range emails {
+		if owner, ok := cs.byEmail[email]; ok && owner != id {
+			return fmt.Errorf("%w: email %s belongs to customer %s", m.ErrInvalidCustomer, email, owner)
+		}
+	}
+	return nil
+}
+
+func (cs *CustomerService) RegisterCustomer(customer *m.Customer) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if err := cs.repo.Save(customer); err != nil {
+		return fmt.Errorf("error occurred while saving customer %w", err)
+	}
+	cs.ids.Observe(customer.Id)
+	for _, email := range customer.GetEmails() {
+		cs.byEmail[email] = customer.Id
+	}
+	return nil
+}
+
+func (cs *CustomerService) UpdateCustomer(id string, emails []string, phone string, tier m.CustomerTier, locale string, openIssueLimit int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	customer := cs.repo.Get(id)
+	if customer == nil {
+		return ErrCustomerNotFound
+	}
+	previous := customer.GetEmails()
+	if err := customer.Update(emails, phone, tier, locale, openIssueLimit); err != nil {
+		return err
+	}
+	for _, email := range previous {
+		delete(cs.byEmail, email)
+	}
+	for _, email := range customer.GetEmails() {
+		cs.byEmail[email] = id
+	}
+	return cs.repo.Save(customer)
+}
+
+func (cs *CustomerService) SetOptedOut(id string, optedOut bool) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	customer := cs.repo.Get(id)
+	if customer == nil {
+		return ErrCustomerNotFound
+	}
+	customer.SetOptedOut(optedOut)
+	return cs.repo.Save(customer)
+}
+
+// Contacted records that the customer got in touch at the given unix time
+func (cs *CustomerService) Contacted(id string, at int64) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	customer := cs.repo.Get(id)
+	if customer == nil {
+		return ErrCustomerNotFound
+	}
+	customer.Contacted(at)
+	return cs.repo.Save(customer)
+}
+
+func (cs *CustomerService) GetCustomer(id string) *m.Customer {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.repo.Get(id)
+}
+
+// GetCustomerByEmail finds the customer reachable at the email, ignoring case, nil when there is none
+func (cs *CustomerService) GetCustomerByEmail(email string) *m.Customer {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	id, ok := cs.byEmail[strings.ToLower(strings.TrimSpace(email))]
+	if !ok {
+		return nil
+	}
+	return cs.repo.Get(id)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+)
+
+// WithCustomerService keeps customers in the given service instead of an in-memory one
+func WithCustomerService(cs *CustomerService) Option {
+	return func(rs *ResolutionService) {
+		rs.customers = cs
+	}
+}
+
+// CustomerHistory is a page of a customer's issues along with how many of their issues are in each status
+type CustomerHistory struct {
+	Customer   *models.Customer           `json:"customer"`
+	Counts     map[models.IssueStatus]int `json:"counts"`
+	OpenIssues int                        `json:"open_issues"`
+	AtLimit    bool                       `json:"at_limit"` // no more issues can be raised until one is resolved
+	Issues     IssuePage                  `json:"issues"`
+}
+
+func (rs *ResolutionService) AddCustomer(emails []string, phone string, tier models.CustomerTier, locale string, openIssueLimit int) (string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	customer, err := rs.customers.NewCustomer(emails, phone, tier, locale, openIssueLimit)
+	if err != nil {
+		return "", err
+	}
+	if err := rs.commit(customerAdded(customer)); err != nil {
+		return "", err
+	}
+	return customer.Id, nil
+}
+
+// UpdateCustomer replaces the customer's emails, phone, tier, locale and open issue limit. Issues already open
+// stay open when the new limit is lower.
+func (rs *ResolutionService) UpdateCustomer(customerId string, emails []string, phone string, tier models.CustomerTier, locale string, openIssueLimit int) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	updated, err := rs.customers.ValidateUpdate(customerId, emails, phone, tier, locale, openIssueLimit)
+	if err != nil {
+		return err
+	}
+	return rs.commit(&events.Event{
+		Type:           events.CustomerUpdated,
+		CustomerId:     customerId,
+		Emails:         updated.Emails,
+		Phone:          updated.Phone,
+		Tier:           updated.Tier,
+		Locale:         updated.Locale,
+		OpenIssueLimit: updated.OpenIssueLimit,
+	})
+}
+
+// SetCustomerOptOut stops or resumes the emails sent to the customer about their issues
+func (rs *ResolutionService) SetCustomerOptOut(customerId string, optOut bool) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.customers.GetCustomer(customerId) == nil {
+		return ErrCustomerNotFound
+	}
+	return rs.commit(&events.Event{Type: events.CustomerOptOutChanged, CustomerId: customerId, OptOut: optOut})
+}
+
+func (rs *ResolutionService) GetCustomer(customerId string) (*models.Customer, error) {
+	customer := rs.customers.GetCustomer(customerId)
+	if customer == nil {
+		return nil, ErrCustomerNotFound
+	}
+	return customer, nil
+}
+
+func (rs *ResolutionService) GetCustomerByEmail(email string) (*models.Customer, error) {
+	customer := rs.customers.GetCustomerByEmail(email)
+	if customer == nil {
+		return nil, ErrCustomerNotFound
+	}
+	return customer, nil
+}
+
+// CustomerIssues returns the page of the customer's issues matching the query, whatever their status, with the
+// counts taken over all of their issues
+func (rs *ResolutionService) CustomerIssues(customerId string, q IssueQuery) (CustomerHistory, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	customer := rs.customers.GetCustomer(customerId)
+	if customer == nil {
+		return CustomerHistory{}, ErrCustomerNotFound
+	}
+	q.CustomerIds = []string{customerId}
+	page, err := rs.issueService.QueryIssues(q)
+	if err != nil {
+		return CustomerHistory{}, err
+	}
+	counts := rs.issueService.StatusCounts(customerId)
+	open := openIssues(counts)
+	limit := customer.GetOpenIssueLimit()
+	return CustomerHistory{
+		Customer:   customer,
+		Counts:     counts,
+		OpenIssues: open,
+		AtLimit:    limit > 0 && open >= limit,
+		Issues:     page,
+	}, nil
+}
+
+func openIssues(counts map[models.IssueStatus]int) int {
+	open := 0
+	for status, count := range counts {
+		if status.IsOpen() {
+			open += count
+		}
+	}
+	return open
+}
+
+// customerFor returns the ID of the customer reachable at the email. When there is none a Standard customer is
+// built and the event registering it returned, for the caller to commit along with its own. Expects rs.mutex to be held.
+func (rs *ResolutionService) customerFor(email string) (string, *events.Event, error) {
+	if customer := rs.customers.GetCustomerByEmail(email); customer != nil {
+		return customer.Id, nil, nil
+	}
+	customer, err := rs.customers.NewCustomer([]string{email}, "", models.Standard, "", 0)
+	if err != nil {
+		return "", nil, err
+	}
+	return customer.Id, customerAdded(customer), nil
+}
+
+// checkOpenIssueLimit expects rs.mutex to be held
+func (rs *ResolutionService) checkOpenIssueLimit(customerId string) error {
+	limit := rs.customers.GetCustomer(customerId).GetOpenIssueLimit()
+	if limit == 0 {
+		return nil
+	}
+	if open := openIssues(rs.issueService.StatusCounts(customerId)); open >= limit {
+		return fmt.Errorf("cannot create issue for customer %s, %w of %d", customerId, ErrOpenIssueLimit, limit)
+	}
+	return nil
+}
+
+// customerContacted records that the customer got in touch, issues raised before customers existed have none
+func (rs *ResolutionService) customerContacted(customerId string, at int64) error {
+	if customerId == "" {
+		return nil
+	}
+	return rs.customers.Contacted(customerId, at)
+}
+
+func customerAdded(customer *models.Customer) *events.Event {
+	return &events.Event{
+		Type:           events.CustomerAdded,
+		CustomerId:     customer.Id,
+		Emails:         customer.Emails,
+		Phone:          customer.Phone,
+		Tier:           customer.Tier,
+		Locale:         customer.Locale,
+		OpenIssueLimit: customer.OpenIssueLimit,
+	}
+}
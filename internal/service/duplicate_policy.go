@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy decides what CreateIssue does with a complaint about a transaction that already has an open issue
+type DuplicatePolicy int
+
+const (
+	// DuplicateLink creates the issue and links it to the open one through DuplicateOf
+	DuplicateLink DuplicatePolicy = iota
+	// DuplicateReject refuses the complaint with ErrDuplicateTransaction
+	DuplicateReject
+	// DuplicateMerge adds the complaint to the timeline of the open issue instead of creating one
+	DuplicateMerge
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateReject:
+		return "reject"
+	case DuplicateMerge:
+		return "merge"
+	default:
+		return "link"
+	}
+}
+
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	for _, p := range []DuplicatePolicy{DuplicateLink, DuplicateReject, DuplicateMerge} {
+		if strings.EqualFold(p.String(), s) {
+			return p, nil
+		}
+	}
+	return DuplicateLink, fmt.Errorf("unknown duplicate policy %q", s)
+}
+
+// WithDuplicatePolicy replaces DuplicateLink as the way CreateIssue handles repeated transactions
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(rs *ResolutionService) {
+		rs.duplicates = policy
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "errors"
+
+var (
+	ErrIssueNotFound        = errors.New("issue not found")
+	ErrAgentNotFound        = errors.New("agent not found")
+	ErrIssueNotAssigned     = errors.New("issue not yet assigned to any agent")
+	ErrIssueAlreadyAssigned = errors.New("issue is already with an agent")
+	ErrNoAgentAvailable     = errors.New("no agent available to take the issue")
+	ErrIssueNotActive       = errors.New("issue is not the active issue of its agent")
+	ErrStatusManaged        = errors.New("status is managed by the resolution workflow")
+	ErrAgentDeactivated     = errors.New("agent is deactivated")
+	ErrAgentUnavailable     = errors.New("agent is not accepting work")
+	ErrInvalidQuery         = errors.New("invalid issue query")
+	ErrDuplicateTransaction = errors.New("transaction already has an open issue")
+	ErrCustomerNotFound     = errors.New("customer not found")
+	ErrOpenIssueLimit       = errors.New("customer has reached their open issue limit")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrDeadLetterNotFound   = errors.New("dead letter not found")
+	ErrDeliveryFailed       = errors.New("webhook delivery failed")
+	ErrEventLogFailed       = errors.New("event log failed, writes are refused until restart")
+)
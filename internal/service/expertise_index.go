@@ -0,0 +1,74 @@
+package service
+
+import (
+	m "iss/internal/models"
+	"sort"
+)
+
+// ExpertiseIndex indexes agents by issue type and by their proficiency in that type
+type ExpertiseIndex map[m.IssueType]map[m.Proficiency]map[string]*m.Agent
+
+func (ix ExpertiseIndex) Add(agent *m.Agent) {
+	for issueType, skill := range agent.GetExpertise() {
+		if _, ok := ix[issueType]; !ok {
+			ix[issueType] = make(map[m.Proficiency]map[string]*m.Agent)
+		}
+		if _, ok := ix[issueType][skill.Level]; !ok {
+			ix[issueType][skill.Level] = make(map[string]*m.Agent)
+		}
+		ix[issueType][skill.Level][agent.Id] = agent
+	}
+}
+
+func (ix ExpertiseIndex) Remove(agent *m.Agent) {
+	for issueType, skill := range agent.GetExpertise() {
+		delete(ix[issueType][skill.Level], agent.Id)
+	}
+}
+
+// Agents returns every indexed agent with the expertise, least utilized first and then by agent ID
+func (ix ExpertiseIndex) Agents(issueType m.IssueType) []*m.Agent {
+	agents := make([]*m.Agent, 0)
+	for _, byId := range ix[issueType] {
+		for _, agent := range byId {
+			agents = append(agents, agent)
+		}
+	}
+	sortAgents(agents)
+	return agents
+}
+
+// AtLevel returns the indexed agents with exactly the given proficiency in the issue type, ordered like Agents
+func (ix ExpertiseIndex) AtLevel(issueType m.IssueType, level m.Proficiency) []*m.Agent {
+	agents := make([]*m.Agent, 0, len(ix[issueType][level]))
+	for _, agent := range ix[issueType][level] {
+		agents = append(agents, agent)
+	}
+	sortAgents(agents)
+	return agents
+}
+
+// Types returns the issue types that have at least one indexed agent
+func (ix ExpertiseIndex) Types() []m.IssueType {
+	types := make([]m.IssueType, 0, len(ix))
+	for issueType, byLevel := range ix {
+		for _, byId := range byLevel {
+			if len(byId) > 0 {
+				types = append(types, issueType)
+				break
+			}
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func sortAgents(agents []*m.Agent) {
+	sort.Slice(agents, func(i, j int) bool {
+		ui, uj := agents[i].Utilization(), agents[j].Utilization()
+		if ui != uj {
+			return ui < uj
+		}
+		return agentIdLess(agents[i].Id, agents[j].Id)
+	})
+}
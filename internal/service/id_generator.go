@@ -0,0 +1,144 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefixes tell issue and agent IDs apart whichever IDGenerator made them
+const (
+	IssueIdPrefix = "I"
+	AgentIdPrefix = "A"
+)
+
+// IDGenerator hands out unique IDs for new issues and agents. Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewId(prefix string) string
+	// Observe is told about every ID loaded from a repository or replayed from the event log so that
+	// the generator never hands it out again
+	Observe(id string)
+}
+
+type IDGenerators int
+
+const (
+	SequenceIDs IDGenerators = iota
+	UUIDs
+	ULIDs
+)
+
+func (g IDGenerators) String() string {
+	switch g {
+	case UUIDs:
+		return "uuid"
+	case ULIDs:
+		return "ulid"
+	default:
+		return "sequence"
+	}
+}
+
+func ParseIDGenerator(s string) (IDGenerators, error) {
+	for _, g := range []IDGenerators{SequenceIDs, UUIDs, ULIDs} {
+		if strings.EqualFold(g.String(), s) {
+			return g, nil
+		}
+	}
+	return SequenceIDs, fmt.Errorf("unknown id generator %q", s)
+}
+
+func GetIDGenerator(g IDGenerators) IDGenerator {
+	switch g {
+	case UUIDs:
+		return UUIDGenerator{}
+	case ULIDs:
+		return ULIDGenerator{}
+	default:
+		return NewSequenceGenerator()
+	}
+}
+
+// WithIDGenerator makes the issue, agent and customer services hand out IDs from the generator, every ID they
+// already hold is observed first
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(rs *ResolutionService) {
+		rs.ids = ids
+	}
+}
+
+// SequenceGenerator numbers IDs per prefix starting at 1, e.g. I1, I2 and A1. The numbers are only unique
+// within one process, every stored ID has to be observed before new ones are handed out.
+type SequenceGenerator struct {
+	next map[string]int
+	mu   sync.Mutex
+}
+
+func NewSequenceGenerator() *SequenceGenerator {
+	return &SequenceGenerator{next: make(map[string]int)}
+}
+
+func (g *SequenceGenerator) NewId(prefix string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next[prefix]++
+	return prefix + strconv.Itoa(g.next[prefix])
+}
+
+// Observe moves the sequence of the ID's prefix past its number, IDs not made of a prefix and a number are ignored
+func (g *SequenceGenerator) Observe(id string) {
+	digits := strings.TrimLeftFunc(id, func(r rune) bool { return r < '0' || r > '9' })
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return
+	}
+	prefix := strings.TrimSuffix(id, digits)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n > g.next[prefix] {
+		g.next[prefix] = n
+	}
+}
+
+// UUIDGenerator makes random version 4 UUIDs, unique across processes without coordination
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewId(prefix string) string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%s%x-%x-%x-%x-%x", prefix, b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (UUIDGenerator) Observe(string) {}
+
+// crockford is the base32 alphabet of ULIDs, it leaves out I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator makes ULIDs, a millisecond timestamp followed by 80 random bits, so that IDs sort by creation time
+type ULIDGenerator struct{}
+
+func (ULIDGenerator) NewId(prefix string) string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	rand.Read(b[6:])
+	n := new(big.Int).SetBytes(b[:])
+	var encoded [26]byte
+	base := big.NewInt(32)
+	digit := new(big.Int)
+	for i := len(encoded) - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		encoded[i] = crockford[digit.Int64()]
+	}
+	return prefix + string(encoded[:])
+}
+
+func (ULIDGenerator) Observe(string) {}
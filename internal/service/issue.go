@@ -3,57 +3,206 @@ package service
 import (
 	"fmt"
 	m "iss/internal/models"
+	"iss/internal/repository"
+	"iss/internal/search"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 type IssueService struct {
-	Issues map[string]*m.Issue
-	mu     sync.RWMutex
+	repo  repository.IssueRepository
+	index *issueIndex
+	text  *search.Index
+	ids   IDGenerator
+	mu    sync.RWMutex
 }
 
-func NewIssueService() *IssueService {
-	return &IssueService{
-		Issues: make(map[string]*m.Issue),
+// NewIssueService keeps issues in memory when no repository is given and indexes the issues already stored
+func NewIssueService(repo repository.IssueRepository) *IssueService {
+	if repo == nil {
+		repo = repository.NewMemoryIssueRepository()
 	}
+	is := &IssueService{
+		repo:  repo,
+		index: newIssueIndex(),
+		text:  search.NewIndex(),
+		ids:   NewSequenceGenerator(),
+	}
+	for _, issue := range repo.List() {
+		is.ids.Observe(issue.Id)
+		is.index.put(issue)
+		is.putText(issue)
+	}
+	return is
+}
+
+func (is *IssueService) CreateIssue(txnID, subject, description, email string, issueType m.IssueType, priority m.Priority) (string, error) {
+	issue, err := is.NewIssue(txnID, subject, description, email, issueType, priority)
+	if err != nil {
+		return "", err
+	}
+	if err := is.AddIssue(issue); err != nil {
+		return "", err
+	}
+	return issue.Id, nil
 }
 
-func (is *IssueService) CreateIssue(txnID, subject, description, email string, issueType m.IssueType) (string, error) {
+// NewIssue validates and builds an issue with the next ID of the generator without storing it
+func (is *IssueService) NewIssue(txnID, subject, description, email string, issueType m.IssueType, priority m.Priority) (*m.Issue, error) {
+	if err := m.ValidateIssue(txnID, subject, description, email); err != nil {
+		fmt.Printf("error occured while creating issue %v \n", err)
+		return nil, fmt.Errorf("error occured while creating issue %w", err)
+	}
+	is.mu.RLock()
+	ids := is.ids
+	is.mu.RUnlock()
+	return m.NewIssue(ids.NewId(IssueIdPrefix), txnID, subject, description, email, issueType, priority)
+}
+
+// SetIDGenerator replaces the sequence issue IDs are taken from, the IDs of the stored issues are observed first
+func (is *IssueService) SetIDGenerator(ids IDGenerator) {
 	is.mu.Lock()
 	defer is.mu.Unlock()
+	for _, issue := range is.repo.List() {
+		ids.Observe(issue.Id)
+	}
+	is.ids = ids
+}
 
-	id := "I" + txnID // in ideal systems we should be using uuid's
-	issue, err := m.NewIssue(id, txnID, subject, description, email, issueType)
-	if err != nil {
-		fmt.Printf("error occured while creating issue %w \n", err)
-		return "", fmt.Errorf("error occured while creating issue %w", err)
+// StatusCounts counts the issues of the customer in each status
+func (is *IssueService) StatusCounts(customerId string) map[m.IssueStatus]int {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	return is.index.statusCounts(customerId)
+}
+
+// OpenIssueByTxnId returns the oldest issue of the transaction that is still open, nil when there is none
+func (is *IssueService) OpenIssueByTxnId(txnID string) *m.Issue {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	var oldest *m.Issue
+	for _, issue := range is.candidates(lookupIn(is.index.byTxnId, []string{txnID})) {
+		if !issue.GetStatus().IsOpen() {
+			continue
+		}
+		if oldest == nil || issue.CreatedAt < oldest.CreatedAt || (issue.CreatedAt == oldest.CreatedAt && issue.Id < oldest.Id) {
+			oldest = issue
+		}
+	}
+	return oldest
+}
+
+func (is *IssueService) AddIssue(issue *m.Issue) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if err := is.repo.Save(issue); err != nil {
+		return fmt.Errorf("error occured while saving issue %w", err)
 	}
-	is.Issues[id] = issue
-	return id, nil
+	is.ids.Observe(issue.Id)
+	is.index.put(issue)
+	is.putText(issue)
+	return nil
+}
+
+// SetAgent records the agent the issue is assigned to for lookups by agent, an empty agentId clears it
+func (is *IssueService) SetAgent(issueId, agentId string) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.index.setAgent(issueId, agentId)
+}
+
+// save stores the issue after a status change, moving it to its new status in the index and indexing the
+// resolution note it may have picked up. Expects is.mu to be held.
+func (is *IssueService) save(issue *m.Issue) error {
+	is.index.put(issue)
+	is.putText(issue)
+	return is.repo.Save(issue)
 }
 
 func (is *IssueService) GetIssue(id string) *m.Issue {
 	is.mu.RLock()
 	defer is.mu.RUnlock()
-	return is.Issues[id]
+	return is.repo.Get(id)
+}
+
+func (is *IssueService) AppendTimeline(issueId string, entry m.TimelineEntry) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if issue := is.repo.Get(issueId); issue != nil {
+		issue.AppendTimeline(entry)
+		if entry.Kind.IsMessage() {
+			is.putText(issue)
+		}
+		return is.repo.Save(issue)
+	}
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
+}
+
+func (is *IssueService) UpdateIssue(issueId, resolution string, status m.IssueStatus, at int64) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if issue := is.repo.Get(issueId); issue != nil {
+		if _, err := issue.UpdateStatusAt(status, resolution, at); err != nil {
+			return err
+		}
+		return is.save(issue)
+	}
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
+}
+
+func (is *IssueService) SetStatus(issueId string, status m.IssueStatus, at int64) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if issue := is.repo.Get(issueId); issue != nil {
+		if err := issue.SetStatusAt(status, at); err != nil {
+			return err
+		}
+		return is.save(issue)
+	}
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
+}
+
+func (is *IssueService) Expedite(issueId string) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if issue := is.repo.Get(issueId); issue != nil {
+		issue.Expedite()
+		return is.save(issue)
+	}
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
+}
+
+func (is *IssueService) ReopenIssue(issueId, reason string, at int64) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if issue := is.repo.Get(issueId); issue != nil {
+		if err := issue.ReopenAt(reason, at); err != nil {
+			return err
+		}
+		return is.save(issue)
+	}
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
 }
 
-func (is *IssueService) UpdateIssue(issueId, resolution string, status m.IssueStatus) error {
+func (is *IssueService) EscalateIssue(issueId string, at int64) error {
 	is.mu.Lock()
 	defer is.mu.Unlock()
-	if issue, exists := is.Issues[issueId]; exists {
-		issue.UpdateStatus(status, resolution)
-		return nil
+	if issue := is.repo.Get(issueId); issue != nil {
+		issue.Escalate(at)
+		return is.save(issue)
 	}
-	return fmt.Errorf("issue with id %s not found", issueId)
+	return fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
 }
 
+// GetIssues returns the issues matching every exact-match filter. The filters on id, txnid, email, type, status
+// and agent_id are served from the indexes, the most selective of them picks the issues the rest are checked on.
 func (is *IssueService) GetIssues(filter map[string]string) []*m.Issue {
 	is.mu.RLock()
 	defer is.mu.RUnlock()
 
 	var filteredIssues []*m.Issue
-	for _, issue := range is.Issues {
+	for _, issue := range is.candidates(is.planFilter(filter)) {
 		found := true
 		for key, value := range filter {
 			switch strings.ToLower(key) {
@@ -69,6 +218,10 @@ func (is *IssueService) GetIssues(filter map[string]string) []*m.Issue {
 				if issue.Type.String() != value {
 					found = false
 				}
+			case "priority":
+				if !strings.EqualFold(issue.Priority.String(), value) {
+					found = false
+				}
 			case "subject":
 				if issue.Subject != value {
 					found = false
@@ -82,13 +235,25 @@ func (is *IssueService) GetIssues(filter map[string]string) []*m.Issue {
 					found = false
 				}
 			case "status":
-				if string(issue.Status) != value {
+				if !strings.EqualFold(issue.GetStatus().String(), value) {
+					found = false
+				}
+			case "agent_id":
+				if is.index.agentOf(issue.Id) != value {
 					found = false
 				}
 			case "resolution":
 				if issue.Resolution != value {
 					found = false
 				}
+			case "reopen_count":
+				if strconv.Itoa(issue.GetReopenCount()) != value {
+					found = false
+				}
+			case "reopened":
+				if reopened, err := strconv.ParseBool(value); err != nil || reopened != (issue.GetReopenCount() > 0) {
+					found = false
+				}
 			default:
 				continue
 			}
@@ -103,3 +268,34 @@ func (is *IssueService) GetIssues(filter map[string]string) []*m.Issue {
 
 	return filteredIssues
 }
+
+// planFilter turns the indexed filters into lookups and picks the most selective. A value an index cannot hold,
+// like an unknown status, becomes an empty lookup since no issue can match it.
+func (is *IssueService) planFilter(filter map[string]string) lookup {
+	lookups := make([]lookup, 0, len(filter))
+	for key, value := range filter {
+		switch strings.ToLower(key) {
+		case "id":
+			lookups = append(lookups, idLookup([]string{value}))
+		case "txnid":
+			lookups = append(lookups, lookupIn(is.index.byTxnId, []string{value}))
+		case "email":
+			lookups = append(lookups, lookupIn(is.index.byEmail, []string{strings.ToLower(value)}))
+		case "agent_id":
+			lookups = append(lookups, lookupIn(is.index.byAgent, []string{value}))
+		case "type":
+			issueType, err := m.ParseIssueType(value)
+			if err != nil {
+				return lookup{}
+			}
+			lookups = append(lookups, lookupIn(is.index.byType, []m.IssueType{issueType}))
+		case "status":
+			status, err := m.ParseIssueStatus(value)
+			if err != nil {
+				return lookup{}
+			}
+			lookups = append(lookups, lookupIn(is.index.byStatus, []m.IssueStatus{status}))
+		}
+	}
+	return plan(lookups...)
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+	"time"
+)
+
+// commit makes each event durable before it changes anything: the event is checked against the current state
+// without changing it, appended to the log, if one is configured, and only then applied and posted to the bus, see
+// WithEventBus. Callers must hold rs.mutex and have validated the operation as a whole. The events of a batch go
+// through one at a time since each is checked against the state the previous ones left, so a rejected event leaves
+// the events before it logged and applied and the rest of the batch neither, either way the log replays to the state
+// in memory.
+//
+// Once an append fails, or an event fails to apply after it was logged, memory and the log can no longer be trusted
+// to agree and the service fails closed: every later commit is refused with ErrEventLogFailed until a restart
+// recovers the state from the log.
+func (rs *ResolutionService) commit(evts ...*events.Event) error {
+	if rs.failed != nil {
+		return fmt.Errorf("%w: %v", ErrEventLogFailed, rs.failed)
+	}
+	now := time.Now().Unix()
+	for _, e := range evts {
+		if e.Timestamp == 0 {
+			e.Timestamp = now
+		}
+		if err := rs.check(*e); err != nil {
+			return fmt.Errorf("error occurred while validating %s %w", e.Type, err)
+		}
+		if rs.log != nil {
+			if err := rs.log.Append(e); err != nil {
+				rs.failed = err
+				return fmt.Errorf("error occurred while logging %s, %w: %v", e.Type, ErrEventLogFailed, err)
+			}
+		}
+		if err := rs.apply(*e); err != nil {
+			if rs.log != nil {
+				rs.failed = fmt.Errorf("logged %s %d failed to apply %w", e.Type, e.Seq, err)
+			}
+			return fmt.Errorf("error occurred while applying %s %w", e.Type, err)
+		}
+		if rs.bus != nil {
+			rs.bus.Post(*e)
+		}
+	}
+	return nil
+}
+
+// apply performs the state transition recorded by the event and adds it to the issue's timeline. It is shared by the
+// live path and by Recover, so every decision (chosen agent, generated IDs, timestamps) must come from the event
+// rather than be recomputed.
+func (rs *ResolutionService) apply(e events.Event) error {
+	if err := rs.applyState(e); err != nil {
+		return err
+	}
+	entry, ok := timelineEntry(e)
+	if !ok {
+		return nil
+	}
+	return rs.issueService.AppendTimeline(e.IssueId, entry)
+}
+
+func (rs *ResolutionService) applyState(e events.Event) error {
+	switch e.Type {
+	case events.IssueCreated:
+		issue, err := models.NewIssue(e.IssueId, e.TxnId, e.Subject, e.Description, e.Email, e.IssueType, e.Priority)
+		if err != nil {
+			return err
+		}
+		issue.CreatedAt = e.Timestamp
+		issue.DuplicateOf = e.DuplicateOf
+		issue.CustomerId = e.CustomerId
+		if err := rs.issueService.AddIssue(issue); err != nil {
+			return err
+		}
+		if e.DuplicateOf != "" {
+			err := rs.issueService.AppendTimeline(e.DuplicateOf, models.TimelineEntry{
+				Kind:   models.Duplicate,
+				Author: e.Email,
+				Body:   fmt.Sprintf("issue %s linked as a duplicate", e.IssueId),
+				At:     e.Timestamp,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return rs.customerContacted(e.CustomerId, e.Timestamp)
+
+	case events.IssueMerged:
+		if rs.issueService.GetIssue(e.IssueId) == nil {
+			return ErrIssueNotFound
+		}
+		return rs.customerContacted(e.CustomerId, e.Timestamp)
+
+	case events.CustomerAdded:
+		customer, err := models.NewCustomer(e.CustomerId, e.Emails, e.Phone, e.Tier, e.Locale, e.OpenIssueLimit)
+		if err != nil {
+			return err
+		}
+		customer.CreatedAt = e.Timestamp
+		return rs.customers.RegisterCustomer(customer)
+
+	case events.CustomerUpdated:
+		return rs.customers.UpdateCustomer(e.CustomerId, e.Emails, e.Phone, e.Tier, e.Locale, e.OpenIssueLimit)
+
+	case events.CustomerOptOutChanged:
+		return rs.customers.SetOptedOut(e.CustomerId, e.OptOut)
+
+	case events.AgentAdded:
+		agent, err := models.NewAgent(e.AgentId, e.Name, e.Email, e.Expertise, e.Capacity)
+		if err != nil {
+			return err
+		}
+		agent.CreatedAt = e.Timestamp
+		return rs.AgentService.RegisterAgent(agent)
+
+	case events.IssueAssigned, events.IssueWaitlisted:
+		issue := rs.issueService.GetIssue(e.IssueId)
+		if issue == nil {
+			return ErrIssueNotFound
+		}
+		agent := rs.AgentService.GetAgent(e.AgentId)
+		if agent == nil {
+			return ErrAgentNotFound
+		}
+		// the agent's availability is checked before anything changes so that a diverged event leaves no trace
+		if agent.IsAvailable() == (e.Type == events.IssueWaitlisted) {
+			return fmt.Errorf("agent %s availability diverged from the event log", e.AgentId)
+		}
+		if err := rs.expedite(e); err != nil {
+			return err
+		}
+		rs.unassigned.Remove(e.IssueId)
+		waitListed, err := rs.AgentService.AssignIssue(agent, issue)
+		if err != nil {
+			return err
+		}
+		if waitListed {
+			return rs.issueService.SetStatus(e.IssueId, models.Waitlisted, e.Timestamp)
+		}
+		if err := rs.setAssignment(e.IssueId, e.AgentId); err != nil {
+			return err
+		}
+		return rs.issueService.SetStatus(e.IssueId, models.Assigned, e.Timestamp)
+
+	case events.IssueParked:
+		issue := rs.issueService.GetIssue(e.IssueId)
+		if issue == nil {
+			return ErrIssueNotFound
+		}
+		if err := rs.expedite(e); err != nil {
+			return err
+		}
+		if err := rs.issueService.SetStatus(e.IssueId, models.Waitlisted, e.Timestamp); err != nil {
+			return err
+		}
+		rs.unassigned.Add(issue)
+		return nil
+
+	case events.IssueStatusChanged:
+		return rs.issueService.UpdateIssue(e.IssueId, e.Resolution, e.Status, e.Timestamp)
+
+	case events.IssueResolved:
+		// the agent picks up its next pending issue in the freed slot here, PendingIssuePromoted only records the new assignment
+		if _, err := rs.AgentService.ResolveIssue(e.AgentId, e.IssueId, e.Resolution); err != nil {
+			return err
+		}
+		return rs.issueService.UpdateIssue(e.IssueId, e.Resolution, models.Resolved, e.Timestamp)
+
+	case events.IssueReopened:
+		// AgentId is the agent that resolved the issue, the reopened issue is routed by a following assignment event
+		if e.AgentId != "" {
+			if err := rs.AgentService.ReopenIssue(e.AgentId, e.IssueId); err != nil {
+				return err
+			}
+		}
+		if err := rs.issueService.ReopenIssue(e.IssueId, e.Reason, e.Timestamp); err != nil {
+			return err
+		}
+		return rs.deleteAssignment(e.IssueId)
+
+	case events.IssueCancelled:
+		// an issue that nobody holds, one created or reopened but never assigned, is only cancelled
+		if _, _, err := rs.withdrawIssue(e.IssueId); err != nil && !errors.Is(err, ErrIssueNotAssigned) {
+			return err
+		}
+		return rs.issueService.SetStatus(e.IssueId, models.Cancelled, e.Timestamp)
+
+	case events.IssueEscalated:
+		// AgentId is only set when the escalation moved the issue out of a busy agent's queue
+		if e.AgentId != "" {
+			if err := rs.takeOverPendingIssue(e.IssueId, e.AgentId, e.Reason, e.Timestamp); err != nil {
+				return err
+			}
+		}
+		return rs.issueService.EscalateIssue(e.IssueId, e.Timestamp)
+
+	case events.PendingIssuePromoted:
+		if err := rs.setAssignment(e.IssueId, e.AgentId); err != nil {
+			return err
+		}
+		return rs.issueService.SetStatus(e.IssueId, models.Assigned, e.Timestamp)
+
+	case events.AgentPresenceChanged:
+		return rs.AgentService.SetPresence(e.AgentId, e.Presence)
+
+	case events.AgentShiftsChanged:
+		return rs.AgentService.SetShifts(e.AgentId, e.Shifts)
+
+	case events.PendingIssueMoved, events.IssueHandedOver, events.IssueTransferred:
+		if err := rs.expedite(e); err != nil {
+			return err
+		}
+		return rs.transferIssue(e.IssueId, e.AgentId, e.Reason, author(e), e.Timestamp)
+
+	case events.IssueCommented:
+		// the comment only lives in the timeline
+		issue := rs.issueService.GetIssue(e.IssueId)
+		if issue == nil {
+			return ErrIssueNotFound
+		}
+		if e.Kind == models.CustomerReply {
+			return rs.customerContacted(issue.CustomerId, e.Timestamp)
+		}
+		return nil
+
+	case events.AgentDeactivated:
+		return rs.AgentService.Deactivate(e.AgentId, e.Timestamp)
+
+	case events.AgentActivated:
+		return rs.AgentService.Activate(e.AgentId)
+
+	case events.AgentRemoved:
+		return rs.AgentService.Remove(e.AgentId)
+
+	default:
+		return fmt.Errorf("unknown event type %s", e.Type)
+	}
+}
+
+// timelineEntry builds the entry the event adds to its issue's timeline, reassignments are recorded by transferIssue
+func timelineEntry(e events.Event) (models.TimelineEntry, bool) {
+	entry := models.TimelineEntry{Author: author(e), At: e.Timestamp}
+	switch e.Type {
+	case events.IssueAssigned, events.PendingIssuePromoted:
+		entry.Kind, entry.AgentId = models.Assignment, e.AgentId
+	case events.IssueWaitlisted:
+		entry.Kind, entry.AgentId, entry.Body = models.Assignment, e.AgentId, "queued behind the agent's pending issues"
+	case events.IssueParked:
+		entry.Kind, entry.Body = models.Assignment, "parked in the unassigned pool"
+	case events.IssueStatusChanged:
+		entry.Kind, entry.Status, entry.Body = models.StatusChange, e.Status, e.Resolution
+	case events.IssueResolved:
+		entry.Kind, entry.Status, entry.Body = models.StatusChange, models.Resolved, e.Resolution
+	case events.IssueReopened:
+		entry.Kind, entry.Status, entry.Body = models.StatusChange, models.Reopened, e.Reason
+	case events.IssueCancelled:
+		entry.Kind, entry.Status, entry.Body = models.StatusChange, models.Cancelled, e.Reason
+	case events.IssueEscalated:
+		entry.Kind, entry.Status, entry.Body = models.StatusChange, models.Escalated, e.Reason
+	case events.IssueCommented:
+		entry.Kind, entry.Body = e.Kind, e.Comment
+	case events.IssueMerged:
+		entry.Kind, entry.Author, entry.Body = models.Duplicate, e.Email, e.Subject+": "+e.Description
+	default:
+		return entry, false
+	}
+	return entry, true
+}
+
+// expedite marks the issue of an event routed for a VIP customer before it is queued anywhere
+func (rs *ResolutionService) expedite(e events.Event) error {
+	if !e.Expedite {
+		return nil
+	}
+	return rs.issueService.Expedite(e.IssueId)
+}
+
+func author(e events.Event) string {
+	if e.Author == "" {
+		return models.SystemAuthor
+	}
+	return e.Author
+}
+
+// takeOverPendingIssue removes a waitlisted issue from the unassigned pool or from whichever agent queued it
+// and hands it to an available agent
+func (rs *ResolutionService) takeOverPendingIssue(issueId, agentId, reason string, at int64) error {
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return ErrIssueNotFound
+	}
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil {
+		return ErrAgentNotFound
+	}
+	if !agent.IsAvailable() {
+		return fmt.Errorf("agent %s availability diverged from the event log", agentId)
+	}
+	from := ""
+	if !rs.unassigned.Remove(issueId) {
+		holder, err := rs.AgentService.RemovePendingIssue(issueId)
+		if err != nil {
+			return err
+		}
+		from = holder.Id
+	}
+	if _, err := rs.AgentService.AssignIssue(agent, issue); err != nil {
+		return err
+	}
+	if err := rs.setAssignment(issueId, agentId); err != nil {
+		return err
+	}
+	reassignment := models.TimelineEntry{Kind: models.Reassignment, Author: models.SystemAuthor, Body: reason, From: from, AgentId: agentId, At: at}
+	return rs.issueService.AppendTimeline(issueId, reassignment)
+}
+
+// transferIssue takes the issue away from whoever holds it, an agent working on it, an agent's queue or the
+// unassigned pool, and hands it to another agent, or parks it in the pool when agentId is empty. An issue that was
+// being worked on becomes Waitlisted when it has to queue, a waiting issue becomes Assigned when the new agent can
+// start on it right away, otherwise the status is kept. The move is recorded in the issue's transfers.
+func (rs *ResolutionService) transferIssue(issueId, agentId, reason, author string, at int64) error {
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return ErrIssueNotFound
+	}
+	from, wasActive, err := rs.withdrawIssue(issueId)
+	if err != nil {
+		return fmt.Errorf("cannot transfer, %w", err)
+	}
+	// only the apply knows where the issue came from, so the reassignment is recorded here rather than by timelineEntry
+	reassignment := models.TimelineEntry{Kind: models.Reassignment, Author: author, Body: reason, From: from, AgentId: agentId, At: at}
+	if err := rs.issueService.AppendTimeline(issueId, reassignment); err != nil {
+		return err
+	}
+
+	if agentId == "" {
+		rs.unassigned.Add(issue)
+		if wasActive {
+			return rs.issueService.SetStatus(issueId, models.Waitlisted, at)
+		}
+		return nil
+	}
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil {
+		return ErrAgentNotFound
+	}
+	waitListed, err := rs.AgentService.AssignIssue(agent, issue)
+	if err != nil {
+		return err
+	}
+	if waitListed {
+		if wasActive {
+			return rs.issueService.SetStatus(issueId, models.Waitlisted, at)
+		}
+		return nil
+	}
+	if err := rs.setAssignment(issueId, agentId); err != nil {
+		return err
+	}
+	if wasActive {
+		return nil
+	}
+	return rs.issueService.SetStatus(issueId, models.Assigned, at)
+}
+
+// withdrawIssue takes the issue away from the agent working on it, from the agent that has it queued or from the
+// unassigned pool, and returns the agent it was with, if any, and whether that agent was working on it. An agent that
+// was working on it promotes its next pending issue, which is recorded by a following PendingIssuePromoted event.
+func (rs *ResolutionService) withdrawIssue(issueId string) (from string, wasActive bool, err error) {
+	if holder := rs.AgentService.GetAgent(rs.issueAgentMap[issueId]); holder != nil && holder.HasActiveIssue(issueId) {
+		if _, err := rs.AgentService.ReleaseIssue(holder.Id, issueId); err != nil {
+			return "", false, err
+		}
+		if err := rs.deleteAssignment(issueId); err != nil {
+			return "", false, err
+		}
+		return holder.Id, true, nil
+	}
+	if holder := rs.AgentService.PendingWith(issueId); holder != nil {
+		if _, err := rs.AgentService.RemovePendingIssue(issueId); err != nil {
+			return "", false, err
+		}
+		return holder.Id, false, nil
+	}
+	if !rs.unassigned.Remove(issueId) {
+		return "", false, ErrIssueNotAssigned
+	}
+	return "", false, nil
+}
+
+// Recover replays the event log into the services. It must be called once, before serving requests,
+// on services backed by empty in-memory repositories.
+func (rs *ResolutionService) Recover() error {
+	if rs.log == nil {
+		return nil
+	}
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return rs.log.Replay(rs.apply)
+}
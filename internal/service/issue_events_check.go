@@ -0,0 +1,255 @@
+package service
+
+import (
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+)
+
+// check validates the event against the current state the way applyState would, without changing anything, so
+// that commit only logs events that apply. What is left for apply to fail on is the repositories failing to save.
+// Expects rs.mutex to be held.
+func (rs *ResolutionService) check(e events.Event) error {
+	switch e.Type {
+	case events.IssueCreated:
+		if _, err := models.NewIssue(e.IssueId, e.TxnId, e.Subject, e.Description, e.Email, e.IssueType, e.Priority); err != nil {
+			return err
+		}
+		if rs.issueService.GetIssue(e.IssueId) != nil {
+			return fmt.Errorf("%w: issue %s already exists", models.ErrInvalidIssue, e.IssueId)
+		}
+		if e.DuplicateOf != "" {
+			if _, err := rs.existingIssue(e.DuplicateOf); err != nil {
+				return err
+			}
+		}
+		return rs.checkContacted(e.CustomerId)
+
+	case events.IssueMerged:
+		if _, err := rs.existingIssue(e.IssueId); err != nil {
+			return err
+		}
+		return rs.checkContacted(e.CustomerId)
+
+	case events.CustomerAdded:
+		customer, err := models.NewCustomer(e.CustomerId, e.Emails, e.Phone, e.Tier, e.Locale, e.OpenIssueLimit)
+		if err != nil {
+			return err
+		}
+		if rs.customers.GetCustomer(e.CustomerId) != nil {
+			return fmt.Errorf("%w: customer %s already exists", models.ErrInvalidCustomer, e.CustomerId)
+		}
+		return rs.customers.checkEmails(e.CustomerId, customer.Emails)
+
+	case events.CustomerUpdated:
+		_, err := rs.customers.ValidateUpdate(e.CustomerId, e.Emails, e.Phone, e.Tier, e.Locale, e.OpenIssueLimit)
+		return err
+
+	case events.CustomerOptOutChanged:
+		if rs.customers.GetCustomer(e.CustomerId) == nil {
+			return ErrCustomerNotFound
+		}
+		return nil
+
+	case events.AgentAdded:
+		if _, err := models.NewAgent(e.AgentId, e.Name, e.Email, e.Expertise, e.Capacity); err != nil {
+			return err
+		}
+		if rs.AgentService.GetAgent(e.AgentId) != nil {
+			return fmt.Errorf("%w: agent %s already exists", models.ErrInvalidAgent, e.AgentId)
+		}
+		return nil
+
+	case events.IssueAssigned, events.IssueWaitlisted:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		agent, err := rs.assignableAgent(e.AgentId)
+		if err != nil {
+			return err
+		}
+		if holder, _ := rs.holderOf(e.IssueId); holder != nil {
+			return fmt.Errorf("%w %s", ErrIssueAlreadyAssigned, holder.Id)
+		}
+		waitListed := e.Type == events.IssueWaitlisted
+		if agent.IsAvailable() == waitListed {
+			return fmt.Errorf("agent %s availability diverged from the event", e.AgentId)
+		}
+		if waitListed {
+			return checkTransition(issue, models.Waitlisted)
+		}
+		return checkTransition(issue, models.Assigned)
+
+	case events.IssueParked:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		return checkTransition(issue, models.Waitlisted)
+
+	case events.IssueStatusChanged:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		if e.Resolution == "" {
+			return fmt.Errorf("%w: resolution cannot be empty", models.ErrInvalidIssue)
+		}
+		return checkTransition(issue, e.Status)
+
+	case events.IssueResolved:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		agent := rs.AgentService.GetAgent(e.AgentId)
+		if agent == nil {
+			return ErrAgentNotFound
+		}
+		if !agent.HasActiveIssue(e.IssueId) {
+			return ErrIssueNotActive
+		}
+		if e.Resolution == "" {
+			return fmt.Errorf("%w: resolution cannot be empty", models.ErrInvalidIssue)
+		}
+		return checkTransition(issue, models.Resolved)
+
+	case events.IssueReopened:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		if e.AgentId != "" {
+			agent := rs.AgentService.GetAgent(e.AgentId)
+			if agent == nil {
+				return ErrAgentNotFound
+			}
+			if _, ok := agent.GetResolvedIssues()[e.IssueId]; !ok {
+				return fmt.Errorf("issue %s was not resolved by agent %s", e.IssueId, e.AgentId)
+			}
+		}
+		return checkTransition(issue, models.Reopened)
+
+	case events.IssueCancelled:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		return checkTransition(issue, models.Cancelled)
+
+	case events.IssueEscalated:
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(issue, models.Escalated); err != nil {
+			return err
+		}
+		if e.AgentId == "" {
+			return nil
+		}
+		// the issue is taken out of the pool or a queue and started on right away
+		agent, err := rs.assignableAgent(e.AgentId)
+		if err != nil {
+			return err
+		}
+		if !agent.IsAvailable() {
+			return fmt.Errorf("agent %s availability diverged from the event", e.AgentId)
+		}
+		if !rs.unassigned.Contains(e.IssueId) && rs.AgentService.PendingWith(e.IssueId) == nil {
+			return fmt.Errorf("issue %s is neither parked nor pending with any agent", e.IssueId)
+		}
+		return nil
+
+	case events.PendingIssuePromoted:
+		// the event follows the one that freed the agent's slot, which already moved the issue into it
+		issue, err := rs.existingIssue(e.IssueId)
+		if err != nil {
+			return err
+		}
+		agent := rs.AgentService.GetAgent(e.AgentId)
+		if agent == nil {
+			return ErrAgentNotFound
+		}
+		if !agent.HasActiveIssue(e.IssueId) {
+			return ErrIssueNotActive
+		}
+		return checkTransition(issue, models.Assigned)
+
+	case events.AgentPresenceChanged, events.AgentShiftsChanged, events.AgentDeactivated, events.AgentActivated, events.AgentRemoved:
+		if rs.AgentService.GetAgent(e.AgentId) == nil {
+			return ErrAgentNotFound
+		}
+		return nil
+
+	case events.PendingIssueMoved, events.IssueHandedOver, events.IssueTransferred:
+		return rs.checkTransfer(e)
+
+	case events.IssueCommented:
+		_, err := rs.existingIssue(e.IssueId)
+		return err
+
+	default:
+		return fmt.Errorf("unknown event type %s", e.Type)
+	}
+}
+
+// checkTransfer mirrors transferIssue: the issue must be somewhere to be taken from, and the status it ends up in
+// must be reachable
+func (rs *ResolutionService) checkTransfer(e events.Event) error {
+	issue, err := rs.existingIssue(e.IssueId)
+	if err != nil {
+		return err
+	}
+	holder, active := rs.holderOf(e.IssueId)
+	if holder == nil && !rs.unassigned.Contains(e.IssueId) {
+		return fmt.Errorf("cannot transfer, %w", ErrIssueNotAssigned)
+	}
+	if e.AgentId == "" {
+		if active {
+			return checkTransition(issue, models.Waitlisted)
+		}
+		return nil
+	}
+	agent, err := rs.assignableAgent(e.AgentId)
+	if err != nil {
+		return err
+	}
+	waitListed := !agent.IsAvailable()
+	if active && waitListed {
+		return checkTransition(issue, models.Waitlisted)
+	}
+	if !active && !waitListed {
+		return checkTransition(issue, models.Assigned)
+	}
+	return nil
+}
+
+func (rs *ResolutionService) existingIssue(issueId string) (*models.Issue, error) {
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return nil, fmt.Errorf("%w: %s", ErrIssueNotFound, issueId)
+	}
+	return issue, nil
+}
+
+// assignableAgent returns the agent when AgentService.AssignIssue would hand it an issue
+func (rs *ResolutionService) assignableAgent(agentId string) (*models.Agent, error) {
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+	if agent.IsDeactivated() {
+		return nil, fmt.Errorf("cannot assign to %s, %w", agentId, ErrAgentDeactivated)
+	}
+	return agent, nil
+}
+
+// checkContacted mirrors customerContacted, issues raised before customers existed have no customer to update
+func (rs *ResolutionService) checkContacted(customerId string) error {
+	if customerId != "" && rs.customers.GetCustomer(customerId) == nil {
+		return ErrCustomerNotFound
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, opts ...Option) *ResolutionService {
+	t.Helper()
+	return NewResolutionService(NewIssueService(nil), NewAgentService(nil), GetAssignmentStrategy(FreeAgentFirst), nil, opts...)
+}
+
+func mustAddAgent(t *testing.T, rs *ResolutionService, name string, capacity int, types ...models.IssueType) string {
+	t.Helper()
+	expertise := make(map[models.IssueType]models.Skill)
+	for _, it := range types {
+		expertise[it] = models.DefaultSkill
+	}
+	id, err := rs.AddAgent(name+"@test.com", name, expertise, capacity)
+	if err != nil {
+		t.Fatalf("AddAgent(%s): %v", name, err)
+	}
+	return id
+}
+
+func mustCreateIssue(t *testing.T, rs *ResolutionService, txnId string, issueType models.IssueType) string {
+	t.Helper()
+	id, err := rs.CreateIssue(txnId, "Payment Failed", "money debited", "customer@test.com", issueType, models.P2)
+	if err != nil {
+		t.Fatalf("CreateIssue(%s): %v", txnId, err)
+	}
+	return id
+}
+
+func openLog(t *testing.T, path string) *events.FileLog {
+	t.Helper()
+	log, err := events.OpenFileLog(path)
+	if err != nil {
+		t.Fatalf("OpenFileLog: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	return log
+}
+
+func TestRecoverRebuildsStateFromLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	rs := newTestService(t, WithEventLog(openLog(t, path)))
+
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	first := mustCreateIssue(t, rs, "T1", models.Payment)
+	second := mustCreateIssue(t, rs, "T2", models.Payment)
+	third := mustCreateIssue(t, rs, "T3", models.Payment)
+	cancelled := mustCreateIssue(t, rs, "T4", models.Payment)
+	for _, id := range []string{first, second, third, cancelled} {
+		if _, _, err := rs.AssignIssue(id); err != nil {
+			t.Fatalf("AssignIssue(%s): %v", id, err)
+		}
+	}
+	if err := rs.ResolveIssue(first, "refunded"); err != nil {
+		t.Fatalf("ResolveIssue: %v", err)
+	}
+	if _, _, err := rs.ReopenIssue(first, "still debited"); err != nil {
+		t.Fatalf("ReopenIssue: %v", err)
+	}
+	if err := rs.CancelIssue(cancelled, "raised twice"); err != nil {
+		t.Fatalf("CancelIssue: %v", err)
+	}
+
+	recovered := newTestService(t, WithEventLog(openLog(t, path)))
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for _, id := range []string{first, second, third, cancelled} {
+		want, got := rs.issueService.GetIssue(id), recovered.issueService.GetIssue(id)
+		if got == nil {
+			t.Fatalf("issue %s was not recovered", id)
+		}
+		if got.GetStatus() != want.GetStatus() {
+			t.Errorf("issue %s recovered as %s, want %s", id, got.GetStatus(), want.GetStatus())
+		}
+		if len(got.GetTimeline()) != len(want.GetTimeline()) {
+			t.Errorf("issue %s recovered %d timeline entries, want %d", id, len(got.GetTimeline()), len(want.GetTimeline()))
+		}
+		if got.GetReopenCount() != want.GetReopenCount() {
+			t.Errorf("issue %s recovered reopen count %d, want %d", id, got.GetReopenCount(), want.GetReopenCount())
+		}
+	}
+	agent := recovered.AgentService.GetAgent(agentId)
+	if !agent.HasActiveIssue(second) {
+		t.Errorf("promoted issue %s is not active with agent %s after recovery", second, agentId)
+	}
+	pending := agent.GetPendingIssues()
+	if len(pending) != 2 || pending[0].Id != third || pending[1].Id != first {
+		t.Errorf("agent queue recovered as %v, want [%s %s]", issueIds(pending), third, first)
+	}
+}
+
+func TestRejectedEventIsNotLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	rs := newTestService(t, WithEventLog(openLog(t, path)))
+
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+	// the second event of the batch is rejected by check, which leaves the first applied and logged
+	err := rs.commit(
+		&events.Event{Type: events.IssueAssigned, IssueId: issueId, AgentId: agentId},
+		&events.Event{Type: events.PendingIssuePromoted, IssueId: "missing", AgentId: agentId},
+		&events.Event{Type: events.IssueCommented, IssueId: issueId, Author: "agent", Kind: models.Comment, Comment: "never applied"},
+	)
+	if !errors.Is(err, ErrIssueNotFound) {
+		t.Fatalf("commit error = %v, want %v", err, ErrIssueNotFound)
+	}
+
+	recovered := newTestService(t, WithEventLog(openLog(t, path)))
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("Recover after a rejected event: %v", err)
+	}
+	issue := recovered.issueService.GetIssue(issueId)
+	if issue.GetStatus() != models.Assigned {
+		t.Errorf("issue recovered as %s, want %s", issue.GetStatus(), models.Assigned)
+	}
+	for _, entry := range issue.GetTimeline() {
+		if entry.Kind == models.Comment {
+			t.Errorf("event after the rejected one was replayed: %+v", entry)
+		}
+	}
+}
+
+// failingLog accepts appends until it is broken
+type failingLog struct {
+	appended []events.Event
+	broken   bool
+}
+
+func (l *failingLog) Append(e *events.Event) error {
+	if l.broken {
+		return errors.New("disk full")
+	}
+	e.Seq = uint64(len(l.appended) + 1)
+	l.appended = append(l.appended, *e)
+	return nil
+}
+
+func (l *failingLog) Replay(fn func(e events.Event) error) error { return nil }
+func (l *failingLog) Close() error                               { return nil }
+
+func TestFailedAppendFailsClosed(t *testing.T) {
+	log := &failingLog{}
+	rs := newTestService(t, WithEventLog(log))
+	mustAddAgent(t, rs, "agent", 1, models.Payment)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+
+	log.broken = true
+	if _, _, err := rs.AssignIssue(issueId); !errors.Is(err, ErrEventLogFailed) {
+		t.Fatalf("AssignIssue with a failing log = %v, want %v", err, ErrEventLogFailed)
+	}
+	// the event that could not be logged was not applied either
+	wantStatus(t, rs, issueId, models.Created)
+	if holder, _ := rs.holderOf(issueId); holder != nil {
+		t.Errorf("issue is with agent %s although its assignment was never logged", holder.Id)
+	}
+
+	log.broken = false
+	if _, err := rs.CreateIssue("T2", "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2); !errors.Is(err, ErrEventLogFailed) {
+		t.Errorf("CreateIssue after the log failed = %v, want %v", err, ErrEventLogFailed)
+	}
+	if len(log.appended) != 3 {
+		t.Errorf("%d events logged, want the agent, the customer and the first issue only", len(log.appended))
+	}
+}
+
+func TestDivergedAssignmentChangesNothing(t *testing.T) {
+	log := &failingLog{}
+	rs := newTestService(t, WithEventLog(log))
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+
+	// the agent is free, so an event queueing the issue behind its work does not apply
+	err := rs.commit(&events.Event{Type: events.IssueWaitlisted, IssueId: issueId, AgentId: agentId})
+	if err == nil {
+		t.Fatal("commit of a waitlisting for a free agent succeeded")
+	}
+	if len(log.appended) != 3 {
+		t.Errorf("%d events logged, want the agent, the customer and the issue only", len(log.appended))
+	}
+	agent := rs.AgentService.GetAgent(agentId)
+	if len(agent.GetPendingIssues()) != 0 || len(agent.GetActiveIssues()) != 0 {
+		t.Errorf("rejected event changed the agent: active %v pending %v", issueIds(agent.GetActiveIssues()), issueIds(agent.GetPendingIssues()))
+	}
+	wantStatus(t, rs, issueId, models.Created)
+	if _, _, err := rs.AssignIssue(issueId); err != nil {
+		t.Errorf("AssignIssue after a rejected event: %v", err)
+	}
+}
+
+func issueIds(issues []*models.Issue) []string {
+	ids := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.Id)
+	}
+	return ids
+}
+
+func TestSlowSubscriberDoesNotHoldUpWrites(t *testing.T) {
+	bus := events.NewBus()
+	release := make(chan struct{})
+	bus.SubscribeAsync(func(e events.Event) { <-release }, 1, 1)
+	rs := newTestService(t, WithEventBus(bus))
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if _, err := rs.CreateIssue(fmt.Sprintf("T%d", i), "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CreateIssue: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes blocked on a subscriber whose buffer is full")
+	}
+	close(release)
+	bus.Close()
+}
+
+func TestSynchronousSubscriberRunsBeforeOperationReturns(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	var seen []events.EventType
+	bus.Subscribe(func(e events.Event) { seen = append(seen, e.Type) }, events.IssueCreated, events.IssueAssigned)
+	rs := newTestService(t, WithEventBus(bus))
+	mustAddAgent(t, rs, "agent", 1, models.Payment)
+
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+	if len(seen) != 1 || seen[0] != events.IssueCreated {
+		t.Fatalf("CreateIssue returned with the subscriber having seen %v, want [%s]", seen, events.IssueCreated)
+	}
+	mustAssign(t, rs, issueId)
+	if len(seen) != 2 || seen[1] != events.IssueAssigned {
+		t.Errorf("AssignIssue returned with the subscriber having seen %v, want %s last", seen, events.IssueAssigned)
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	m "iss/internal/models"
+	"strings"
+)
+
+// idSet holds issue IDs
+type idSet map[string]struct{}
+
+// indexEntry is what an issue is currently indexed under, so that it can be taken out again when it changes
+type indexEntry struct {
+	email      string
+	txnId      string
+	typ        m.IssueType
+	status     m.IssueStatus
+	agentId    string
+	customerId string
+}
+
+// issueIndex maps the fields issues are most often looked up by to the IDs of the issues holding them. Emails are
+// indexed lowercased. It is not safe for concurrent use, IssueService guards it with its own lock.
+type issueIndex struct {
+	byEmail    map[string]idSet
+	byTxnId    map[string]idSet
+	byType     map[m.IssueType]idSet
+	byStatus   map[m.IssueStatus]idSet
+	byAgent    map[string]idSet
+	byCustomer map[string]idSet
+	entries    map[string]indexEntry
+}
+
+func newIssueIndex() *issueIndex {
+	return &issueIndex{
+		byEmail:    make(map[string]idSet),
+		byTxnId:    make(map[string]idSet),
+		byType:     make(map[m.IssueType]idSet),
+		byStatus:   make(map[m.IssueStatus]idSet),
+		byAgent:    make(map[string]idSet),
+		byCustomer: make(map[string]idSet),
+		entries:    make(map[string]indexEntry),
+	}
+}
+
+func addTo[K comparable](index map[K]idSet, key K, id string) {
+	ids, ok := index[key]
+	if !ok {
+		ids = make(idSet)
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func removeFrom[K comparable](index map[K]idSet, key K, id string) {
+	if ids, ok := index[key]; ok {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+// put indexes the issue as it is now, replacing what it was indexed under before. The assigned agent is
+// only changed by setAgent.
+func (ix *issueIndex) put(issue *m.Issue) {
+	entry, indexed := ix.entries[issue.Id]
+	if indexed {
+		ix.remove(issue.Id)
+	}
+	entry = indexEntry{
+		email:      strings.ToLower(issue.Email),
+		txnId:      issue.TxnId,
+		typ:        issue.Type,
+		status:     issue.GetStatus(),
+		agentId:    entry.agentId,
+		customerId: issue.CustomerId,
+	}
+	ix.entries[issue.Id] = entry
+	addTo(ix.byEmail, entry.email, issue.Id)
+	addTo(ix.byTxnId, entry.txnId, issue.Id)
+	addTo(ix.byType, entry.typ, issue.Id)
+	addTo(ix.byStatus, entry.status, issue.Id)
+	if entry.agentId != "" {
+		addTo(ix.byAgent, entry.agentId, issue.Id)
+	}
+	if entry.customerId != "" {
+		addTo(ix.byCustomer, entry.customerId, issue.Id)
+	}
+}
+
+func (ix *issueIndex) remove(id string) {
+	entry, ok := ix.entries[id]
+	if !ok {
+		return
+	}
+	removeFrom(ix.byEmail, entry.email, id)
+	removeFrom(ix.byTxnId, entry.txnId, id)
+	removeFrom(ix.byType, entry.typ, id)
+	removeFrom(ix.byStatus, entry.status, id)
+	removeFrom(ix.byAgent, entry.agentId, id)
+	removeFrom(ix.byCustomer, entry.customerId, id)
+	delete(ix.entries, id)
+}
+
+// setAgent records the agent the issue is assigned to, an empty agentId clears it
+func (ix *issueIndex) setAgent(id, agentId string) {
+	entry, ok := ix.entries[id]
+	if !ok {
+		return
+	}
+	removeFrom(ix.byAgent, entry.agentId, id)
+	entry.agentId = agentId
+	ix.entries[id] = entry
+	if agentId != "" {
+		addTo(ix.byAgent, agentId, id)
+	}
+}
+
+func (ix *issueIndex) agentOf(id string) string {
+	return ix.entries[id].agentId
+}
+
+// statusCounts counts the issues of the customer in each status
+func (ix *issueIndex) statusCounts(customerId string) map[m.IssueStatus]int {
+	counts := make(map[m.IssueStatus]int)
+	for id := range ix.byCustomer[customerId] {
+		counts[ix.entries[id].status]++
+	}
+	return counts
+}
+
+// lookup is the union of the sets the index holds for the given keys
+type lookup []idSet
+
+func (l lookup) size() int {
+	size := 0
+	for _, ids := range l {
+		size += len(ids)
+	}
+	return size
+}
+
+func lookupIn[K comparable](index map[K]idSet, keys []K) lookup {
+	l := make(lookup, 0, len(keys))
+	for _, key := range keys {
+		if ids, ok := index[key]; ok {
+			l = append(l, ids)
+		}
+	}
+	return l
+}
+
+// plan picks the most selective of the lookups, the one yielding the fewest IDs. A nil plan means no index
+// applies and every issue has to be scanned.
+func plan(lookups ...lookup) lookup {
+	var best lookup
+	bestSize := -1
+	for _, l := range lookups {
+		if l == nil {
+			continue
+		}
+		if size := l.size(); bestSize < 0 || size < bestSize {
+			best, bestSize = l, size
+		}
+	}
+	return best
+}
+
+// candidates returns the issues the plan points at, or every issue when there is no plan. A plan built from
+// repeated keys names the same issue twice, duplicates are dropped.
+func (is *IssueService) candidates(l lookup) []*m.Issue {
+	if l == nil {
+		return is.repo.List()
+	}
+	issues := make([]*m.Issue, 0, l.size())
+	var seen idSet
+	if len(l) > 1 {
+		seen = make(idSet, l.size())
+	}
+	for _, ids := range l {
+		for id := range ids {
+			if seen != nil {
+				if _, dup := seen[id]; dup {
+					continue
+				}
+				seen[id] = struct{}{}
+			}
+			if issue := is.repo.Get(id); issue != nil {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// idLookup turns a list of issue IDs into a lookup so that the planner can weigh it like any index
+func idLookup(ids []string) lookup {
+	set := make(idSet, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return lookup{set}
+}
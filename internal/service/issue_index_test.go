@@ -0,0 +1,142 @@
+package service
+
+import (
+	"flag"
+	"fmt"
+	"iss/internal/models"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var benchIssueTypes = []models.IssueType{models.Payment, models.MutualFund, models.Gold, models.Insurance}
+
+// newIndexFixture creates n issues from the given number of customers, every other one assigned to one of agents
+func newIndexFixture(tb testing.TB, n, customers, agents int) *IssueService {
+	tb.Helper()
+	is := NewIssueService(nil)
+	for i := 0; i < n; i++ {
+		email := fmt.Sprintf("customer%d@test.com", i%customers)
+		id, err := is.CreateIssue(fmt.Sprintf("T%d", i), "Payment Failed", "My payment failed but money is debited", email, benchIssueTypes[i%len(benchIssueTypes)], models.Priority(i%4))
+		if err != nil {
+			tb.Fatalf("CreateIssue: %v", err)
+		}
+		if i%2 == 0 {
+			if err := is.SetStatus(id, models.Assigned, int64(i)); err != nil {
+				tb.Fatalf("SetStatus: %v", err)
+			}
+			is.SetAgent(id, fmt.Sprintf("A%d", i%agents+1))
+		}
+	}
+	return is
+}
+
+// issueLookup is a lookup the indexes serve and the predicate a full scan of the repository finds the same issues by
+type issueLookup struct {
+	indexed func(is *IssueService) []*models.Issue
+	matches func(is *IssueService, issue *models.Issue) bool
+}
+
+var (
+	goldQuery = IssueQuery{Emails: []string{"Customer42@test.com"}, Types: []models.IssueType{models.Gold}, Limit: 1000}
+	// scannedGoldQuery is goldQuery normalized the way QueryIssues matches issues against it
+	scannedGoldQuery = normalized(goldQuery)
+)
+
+func normalized(q IssueQuery) IssueQuery {
+	q.normalize()
+	return q
+}
+
+// issueLookups are checked and benchmarked against scanIssues
+var issueLookups = map[string]issueLookup{
+	"GetIssuesByEmail": {
+		indexed: func(is *IssueService) []*models.Issue {
+			return is.GetIssues(map[string]string{"email": "customer42@test.com"})
+		},
+		matches: func(is *IssueService, issue *models.Issue) bool { return issue.Email == "customer42@test.com" },
+	},
+	"GetIssuesByTxnId": {
+		indexed: func(is *IssueService) []*models.Issue {
+			return is.GetIssues(map[string]string{"txnid": "T500"})
+		},
+		matches: func(is *IssueService, issue *models.Issue) bool { return issue.TxnId == "T500" },
+	},
+	"GetIssuesByAgentAndStatus": {
+		indexed: func(is *IssueService) []*models.Issue {
+			return is.GetIssues(map[string]string{"agent_id": "A7", "status": "Assigned"})
+		},
+		matches: func(is *IssueService, issue *models.Issue) bool {
+			return is.index.agentOf(issue.Id) == "A7" && issue.GetStatus() == models.Assigned
+		},
+	},
+	"QueryIssuesByEmailAndType": {
+		indexed: func(is *IssueService) []*models.Issue {
+			page, _ := is.QueryIssues(goldQuery)
+			return page.Issues
+		},
+		matches: func(is *IssueService, issue *models.Issue) bool {
+			return scannedGoldQuery.matches(issue, is.index.agentOf)
+		},
+	},
+}
+
+// scanIssues is the baseline the indexes are measured against, it checks every stored issue
+func scanIssues(is *IssueService, matches func(is *IssueService, issue *models.Issue) bool) []*models.Issue {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	found := make([]*models.Issue, 0)
+	for _, issue := range is.repo.List() {
+		if matches(is, issue) {
+			found = append(found, issue)
+		}
+	}
+	return found
+}
+
+func TestIndexedLookupsMatchScan(t *testing.T) {
+	is := newIndexFixture(t, 2000, 100, 10)
+	for name, lookup := range issueLookups {
+		indexed := issueIds(lookup.indexed(is))
+		scanned := issueIds(scanIssues(is, lookup.matches))
+		slices.Sort(indexed)
+		slices.Sort(scanned)
+		if len(indexed) == 0 || !slices.Equal(indexed, scanned) {
+			t.Errorf("%s found %v through the indexes and %v by scanning", name, indexed, scanned)
+		}
+	}
+}
+
+var (
+	benchIssues      = flag.Int("issues", 1_000_000, "number of issues the lookup benchmarks run against")
+	benchFixture     *IssueService
+	benchFixtureOnce sync.Once
+)
+
+// benchmarkLookup runs the lookup through the indexes and as a full scan of the same IssueService
+func benchmarkLookup(b *testing.B, name string) {
+	benchFixtureOnce.Do(func() {
+		// spread like production, ten issues per customer and a thousand per agent
+		benchFixture = newIndexFixture(b, *benchIssues, max(*benchIssues/10, 1), max(*benchIssues/1000, 1))
+	})
+	lookup := issueLookups[name]
+	b.Run("indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			lookup.indexed(benchFixture)
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			scanIssues(benchFixture, lookup.matches)
+		}
+	})
+}
+
+func BenchmarkGetIssuesByEmail(b *testing.B) { benchmarkLookup(b, "GetIssuesByEmail") }
+func BenchmarkGetIssuesByTxnId(b *testing.B) { benchmarkLookup(b, "GetIssuesByTxnId") }
+func BenchmarkGetIssuesByAgentAndStatus(b *testing.B) {
+	benchmarkLookup(b, "GetIssuesByAgentAndStatus")
+}
+func BenchmarkQueryIssuesByEmailAndType(b *testing.B) {
+	benchmarkLookup(b, "QueryIssuesByEmailAndType")
+}
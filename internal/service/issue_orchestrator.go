@@ -2,8 +2,9 @@ package service
 
 import (
 	"fmt"
+	"iss/internal/events"
 	"iss/internal/models"
-	"os"
+	"iss/internal/repository"
 	"sync"
 )
 
@@ -11,28 +12,227 @@ type ResolutionService struct {
 	issueService  *IssueService
 	AgentService  *AgentService
 	strategy      AssignmentStrategy
+	assignments   repository.AssignmentRepository
 	issueAgentMap map[string]string
+	unassigned    *UnassignedPool
+	log           events.Log
+	bus           *events.Bus
+	slaPolicy     *SLAPolicy
+	duplicates    DuplicatePolicy
+	customers     *CustomerService
+	ids           IDGenerator
+	failed        error // set once the log and memory may disagree, see commit
 	mutex         sync.RWMutex
 }
 
-func NewResolutionService(issueService *IssueService, agentService *AgentService, strategy AssignmentStrategy) *ResolutionService {
+type Option func(rs *ResolutionService)
+
+// WithEventLog makes every state transition durable in the log before it is applied, see commit
+func WithEventLog(log events.Log) Option {
+	return func(rs *ResolutionService) {
+		rs.log = log
+	}
+}
+
+// WithEventBus posts every committed transition to the bus. Synchronous subscribers run under the service's lock
+// before the operation returns, asynchronous ones get the event from the bus's outbox, so a slow one only holds up
+// the workflow once the outbox is full and the bus blocks. Events replayed by Recover are not published again.
+func WithEventBus(bus *events.Bus) Option {
+	return func(rs *ResolutionService) {
+		rs.bus = bus
+	}
+}
+
+// NewResolutionService rebuilds issueAgentMap from the assignment repository, which defaults to an in-memory one
+func NewResolutionService(issueService *IssueService, agentService *AgentService, strategy AssignmentStrategy, assignments repository.AssignmentRepository, opts ...Option) *ResolutionService {
 	if strategy == nil {
 		strategy = &FreeAgentFirstStrategy{}
 	}
-	return &ResolutionService{
+	if assignments == nil {
+		assignments = repository.NewMemoryAssignmentRepository()
+	}
+	rs := &ResolutionService{
 		issueService:  issueService,
 		AgentService:  agentService,
 		strategy:      strategy,
-		issueAgentMap: make(map[string]string),
+		assignments:   assignments,
+		issueAgentMap: assignments.All(),
+		unassigned:    NewUnassignedPool(),
+	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	if rs.slaPolicy == nil {
+		rs.slaPolicy = DefaultSLAPolicy()
+	}
+	if rs.customers == nil {
+		rs.customers = NewCustomerService(nil)
+	}
+	if rs.ids != nil {
+		rs.issueService.SetIDGenerator(rs.ids)
+		rs.AgentService.SetIDGenerator(rs.ids)
+		rs.customers.SetIDGenerator(rs.ids)
+	}
+	for issueId, agentId := range rs.issueAgentMap {
+		issueService.SetAgent(issueId, agentId)
+	}
+	rs.rebuildUnassignedPool()
+	return rs
+}
+
+// rebuildUnassignedPool parks every waiting issue that is neither assigned nor queued with any agent, which
+// is how a parked issue looks once it has been loaded back from the repositories. Escalated issues can be
+// waiting too when they were moved out of the queue of an agent that went offline.
+func (rs *ResolutionService) rebuildUnassignedPool() {
+	queued := make(map[string]bool)
+	for _, agent := range rs.AgentService.GetAgents() {
+		for _, issue := range agent.GetPendingIssues() {
+			queued[issue.Id] = true
+		}
+	}
+	for _, status := range []models.IssueStatus{models.Waitlisted, models.Escalated} {
+		for _, issue := range rs.issueService.GetIssues(map[string]string{"status": status.String()}) {
+			if _, assigned := rs.issueAgentMap[issue.Id]; !assigned && !queued[issue.Id] {
+				rs.unassigned.Add(issue)
+			}
+		}
 	}
 }
 
-func (rs *ResolutionService) CreateIssue(txnID, subject, description, email string, issueType models.IssueType) (string, error) {
-	return rs.issueService.CreateIssue(txnID, subject, description, email, issueType)
+func (rs *ResolutionService) pooling() bool {
+	pooler, ok := rs.strategy.(UnassignedPooler)
+	return ok && pooler.PoolUnassigned()
+}
+
+// route asks the assignment strategy for the issue's agent, telling it the tier of the issue's customer.
+// Expects rs.mutex to be held.
+func (rs *ResolutionService) route(issue *models.Issue) *models.Agent {
+	return rs.strategy.Assign(issue, rs.tierOf(issue), rs.AgentService.GetAvailableAgentsByExpertise(), rs.AgentService.GetBusyAgentHeap())
+}
+
+// expedites reports whether the strategy lets the issue jump ahead of the pending issues wherever it is queued
+func (rs *ResolutionService) expedites(issue *models.Issue) bool {
+	expediter, ok := rs.strategy.(Expediter)
+	return ok && expediter.Expedites(issue, rs.tierOf(issue))
+}
+
+// tierOf returns the tier of the issue's customer, issues raised before customers existed are Standard
+func (rs *ResolutionService) tierOf(issue *models.Issue) models.CustomerTier {
+	if customer := rs.customers.GetCustomer(issue.CustomerId); customer != nil {
+		return customer.GetTier()
+	}
+	return models.Standard
+}
+
+func (rs *ResolutionService) setAssignment(issueId, agentId string) error {
+	rs.issueAgentMap[issueId] = agentId
+	rs.issueService.SetAgent(issueId, agentId)
+	if err := rs.assignments.Set(issueId, agentId); err != nil {
+		return fmt.Errorf("error occurred while saving assignment %w", err)
+	}
+	return nil
+}
+
+func (rs *ResolutionService) deleteAssignment(issueId string) error {
+	delete(rs.issueAgentMap, issueId)
+	rs.issueService.SetAgent(issueId, "")
+	if err := rs.assignments.Delete(issueId); err != nil {
+		return fmt.Errorf("error occurred while deleting assignment %w", err)
+	}
+	return nil
+}
+
+// CreateIssue raises an issue for the customer reachable at the email, registering a Standard customer when there
+// is none, and returns its ID. A complaint about a transaction that already has an open issue is handled by the
+// DuplicatePolicy, when it is merged the ID of the open issue is returned. A customer at their open issue limit
+// cannot raise more issues.
+func (rs *ResolutionService) CreateIssue(txnID, subject, description, email string, issueType models.IssueType, priority models.Priority) (string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if err := models.ValidateIssue(txnID, subject, description, email); err != nil {
+		return "", fmt.Errorf("error occured while creating issue %w", err)
+	}
+	open := rs.issueService.OpenIssueByTxnId(txnID)
+	if open != nil && rs.duplicates == DuplicateReject {
+		return "", fmt.Errorf("cannot create issue for transaction %s, %w %s", txnID, ErrDuplicateTransaction, open.Id)
+	}
+	customerId, added, err := rs.customerFor(email)
+	if err != nil {
+		return "", err
+	}
+	evts := make([]*events.Event, 0, 2)
+	if added != nil {
+		evts = append(evts, added)
+	}
+
+	if open != nil && rs.duplicates == DuplicateMerge {
+		evts = append(evts, &events.Event{
+			Type:        events.IssueMerged,
+			IssueId:     open.Id,
+			Subject:     subject,
+			Description: description,
+			Email:       email,
+			CustomerId:  customerId,
+		})
+		if err := rs.commit(evts...); err != nil {
+			return "", err
+		}
+		fmt.Printf("Complaint about transaction %s merged into issue %s \n", txnID, open.Id)
+		return open.Id, nil
+	}
+	duplicateOf := ""
+	if open != nil {
+		duplicateOf = open.Id
+	}
+	if added == nil {
+		if err := rs.checkOpenIssueLimit(customerId); err != nil {
+			return "", err
+		}
+	}
+
+	issue, err := rs.issueService.NewIssue(txnID, subject, description, email, issueType, priority)
+	if err != nil {
+		return "", err
+	}
+	evts = append(evts, &events.Event{
+		Type:        events.IssueCreated,
+		IssueId:     issue.Id,
+		TxnId:       issue.TxnId,
+		IssueType:   issue.Type,
+		Priority:    issue.Priority,
+		Subject:     issue.Subject,
+		Description: issue.Description,
+		Email:       issue.Email,
+		DuplicateOf: duplicateOf,
+		CustomerId:  customerId,
+	})
+	if err := rs.commit(evts...); err != nil {
+		return "", err
+	}
+	return issue.Id, nil
 }
 
-func (rs *ResolutionService) AddAgent(email, name string, expertise map[models.IssueType]bool) (string, error) {
-	return rs.AgentService.AddAgent(email, name, expertise)
+func (rs *ResolutionService) AddAgent(email, name string, expertise map[models.IssueType]models.Skill, capacity int) (string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	agent, err := rs.AgentService.NewAgent(email, name, expertise, capacity)
+	if err != nil {
+		return "", err
+	}
+	err = rs.commit(&events.Event{
+		Type:      events.AgentAdded,
+		AgentId:   agent.Id,
+		Name:      agent.Name,
+		Email:     agent.Email,
+		Expertise: agent.Expertise,
+		Capacity:  agent.Capacity,
+	})
+	if err != nil {
+		return "", err
+	}
+	return agent.Id, rs.drainUnassigned()
 }
 
 func (rs *ResolutionService) AssignIssue(issueId string) (string, bool, error) {
@@ -42,36 +242,240 @@ func (rs *ResolutionService) AssignIssue(issueId string) (string, bool, error) {
 
 	issue := rs.issueService.GetIssue(issueId)
 	if issue == nil {
-		return "", waitListed, fmt.Errorf("issue not found")
+		return "", waitListed, ErrIssueNotFound
+	}
+	// an issue an agent is working on or has queued moves through ReassignIssue, assigning it again would book it twice
+	if holder, _ := rs.holderOf(issueId); holder != nil {
+		return "", waitListed, fmt.Errorf("cannot assign, %w %s", ErrIssueAlreadyAssigned, holder.Id)
 	}
 
-	targetAgent := rs.strategy.Assign(issue, rs.AgentService.GetAvailableAgentsByExpertise(), rs.AgentService.GetBusyAgentHeap())
+	targetAgent := rs.route(issue)
+	expedite := rs.expedites(issue)
 	if targetAgent == nil {
-		fmt.Println(rs.AgentService.GetAvailableAgentsByExpertise())
-		fmt.Println(rs.AgentService.GetBusyAgentHeap())
-		os.Exit(0)
+		if !rs.pooling() {
+			return "", waitListed, ErrNoAgentAvailable
+		}
+		// parked issues are reported as waitlisted without an agent
+		if err := checkTransition(issue, models.Waitlisted); err != nil {
+			return "", waitListed, err
+		}
+		if err := rs.commit(&events.Event{Type: events.IssueParked, IssueId: issueId, Expedite: expedite}); err != nil {
+			return "", waitListed, fmt.Errorf("error occurred - assign issue %w", err)
+		}
+		return "", true, nil
 	}
-	waitListed, err := rs.AgentService.AssignIssue(targetAgent, issue)
+	assigned, waitListed, err := assignmentEvent(issue, targetAgent)
 	if err != nil {
+		return "", waitListed, err
+	}
+	assigned.Expedite = expedite
+	if err := rs.commit(assigned); err != nil {
 		return "", waitListed, fmt.Errorf("error occurred - assign issue %w", err)
 	}
-	if !waitListed {
-		rs.issueAgentMap[issueId] = targetAgent.Id
+	return targetAgent.Id, waitListed, nil
+}
+
+// holderOf returns the agent working on the issue, with active set, or the agent that has it queued. Expects
+// rs.mutex to be held.
+func (rs *ResolutionService) holderOf(issueId string) (agent *models.Agent, active bool) {
+	if holder := rs.AgentService.GetAgent(rs.issueAgentMap[issueId]); holder != nil && holder.HasActiveIssue(issueId) {
+		return holder, true
+	}
+	return rs.AgentService.PendingWith(issueId), false
+}
+
+// assignmentEvent builds the event handing the issue to the agent, waitlisting it when the agent is busy
+func assignmentEvent(issue *models.Issue, agent *models.Agent) (*events.Event, bool, error) {
+	waitListed := !agent.IsAvailable()
+	eventType, status := events.IssueAssigned, models.Assigned
+	if waitListed {
+		eventType, status = events.IssueWaitlisted, models.Waitlisted
+	}
+	if err := checkTransition(issue, status); err != nil {
+		return nil, waitListed, err
+	}
+	return &events.Event{Type: eventType, IssueId: issue.Id, AgentId: agent.Id}, waitListed, nil
+}
+
+// ReopenIssue moves a resolved issue back into the workflow. It goes back to the agent that resolved it,
+// behind whatever that agent already has pending, and only falls back to the assignment strategy when that
+// agent is gone, deactivated or not Online. An empty agent ID means nobody could take the issue, it is then
+// parked in the unassigned pool when the strategy uses one and otherwise stays Reopened until AssignIssue.
+func (rs *ResolutionService) ReopenIssue(issueId, reason string) (string, bool, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return "", false, ErrIssueNotFound
+	}
+	if err := checkTransition(issue, models.Reopened); err != nil {
+		return "", false, err
+	}
+	if reason == "" {
+		return "", false, fmt.Errorf("%w: reason cannot be empty", models.ErrInvalidIssue)
+	}
+
+	previousAgentId := rs.issueAgentMap[issueId]
+	reopened := &events.Event{Type: events.IssueReopened, IssueId: issueId, AgentId: previousAgentId, Reason: reason}
+	targetAgent := rs.AgentService.GetAgent(previousAgentId)
+	if targetAgent == nil || !targetAgent.AcceptsWork() {
+		targetAgent = rs.route(issue)
+	}
+	expedite := rs.expedites(issue)
+	if targetAgent == nil && rs.pooling() {
+		return "", true, rs.commit(reopened, &events.Event{Type: events.IssueParked, IssueId: issueId, Expedite: expedite})
+	}
+	if targetAgent == nil {
+		return "", false, rs.commit(reopened)
+	}
+
+	// a Reopened issue may always move to Assigned or Waitlisted, so unlike AssignIssue there is nothing to check
+	waitListed := !targetAgent.IsAvailable()
+	assigned := &events.Event{Type: events.IssueAssigned, IssueId: issueId, AgentId: targetAgent.Id, Expedite: expedite}
+	if waitListed {
+		assigned.Type = events.IssueWaitlisted
+	}
+	if err := rs.commit(reopened, assigned); err != nil {
+		return "", waitListed, fmt.Errorf("error occurred - reopen issue %w", err)
 	}
 	return targetAgent.Id, waitListed, nil
 }
 
+// ReassignIssue moves an issue to the given agent on a supervisor's request, wherever it currently is: with an
+// agent working on it, in an agent's queue or in the unassigned pool. The target must be accepting work, it takes
+// the issue right away when below capacity and queues it otherwise. An agent giving up an issue it was working on picks up its next pending one.
+func (rs *ResolutionService) ReassignIssue(issueId, targetAgentId, reason string) (bool, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return false, ErrIssueNotFound
+	}
+	if reason == "" {
+		return false, fmt.Errorf("%w: reason cannot be empty", models.ErrInvalidIssue)
+	}
+	target := rs.AgentService.GetAgent(targetAgentId)
+	if target == nil || target.IsRemoved() {
+		return false, ErrAgentNotFound
+	}
+	if target.IsDeactivated() {
+		return false, fmt.Errorf("cannot reassign to %s, %w", targetAgentId, ErrAgentDeactivated)
+	}
+	if !target.AcceptsWork() {
+		return false, fmt.Errorf("cannot reassign to %s while %s, %w", targetAgentId, target.GetPresence(), ErrAgentUnavailable)
+	}
+
+	source, active := rs.holderOf(issueId)
+	if source == nil && !rs.unassigned.Contains(issueId) {
+		return false, fmt.Errorf("cannot reassign, %w", ErrIssueNotAssigned)
+	}
+	if source != nil && source.Id == targetAgentId {
+		return false, fmt.Errorf("%w: issue %s is already with agent %s", models.ErrInvalidIssue, issueId, targetAgentId)
+	}
+
+	waitListed := !target.IsAvailable()
+	if active && waitListed {
+		if err := checkTransition(issue, models.Waitlisted); err != nil {
+			return waitListed, err
+		}
+	}
+	if !active && !waitListed {
+		if err := checkTransition(issue, models.Assigned); err != nil {
+			return waitListed, err
+		}
+	}
+
+	evts := []*events.Event{{Type: events.IssueTransferred, IssueId: issueId, AgentId: targetAgentId, Reason: reason}}
+	var next *models.Issue
+	if active {
+		next = source.PeekPendingIssue()
+	}
+	if next != nil {
+		evts = append(evts, &events.Event{Type: events.PendingIssuePromoted, IssueId: next.Id, AgentId: source.Id})
+	}
+	if err := rs.commit(evts...); err != nil {
+		return waitListed, fmt.Errorf("error occurred - reassign issue %w", err)
+	}
+	fmt.Printf("Issue %s has been reassigned to agent %s: %s \n", issueId, targetAgentId, reason)
+	if active && next == nil {
+		// the source agent has a free slot now
+		return waitListed, rs.drainUnassigned()
+	}
+	return waitListed, nil
+}
+
+// AddComment appends a message to the issue's timeline. Only comments, customer replies and internal notes can
+// be added this way, the other kinds are recorded by the workflow itself.
+func (rs *ResolutionService) AddComment(issueId string, kind models.EntryKind, author, body string) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.issueService.GetIssue(issueId) == nil {
+		return ErrIssueNotFound
+	}
+	if !kind.IsMessage() {
+		return fmt.Errorf("%w: %s entries are recorded by the workflow", models.ErrInvalidIssue, kind)
+	}
+	if author == "" || body == "" {
+		return fmt.Errorf("%w: author and body cannot be empty", models.ErrInvalidIssue)
+	}
+	return rs.commit(&events.Event{Type: events.IssueCommented, IssueId: issueId, Author: author, Kind: kind, Comment: body})
+}
+
+// GetTimeline returns every entry recorded for the issue, oldest first
+func (rs *ResolutionService) GetTimeline(issueId string) ([]models.TimelineEntry, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return nil, ErrIssueNotFound
+	}
+	return issue.GetTimeline(), nil
+}
+
 func (rs *ResolutionService) GetIssues(filter map[string]string) []*models.Issue {
 	return rs.issueService.GetIssues(filter)
 }
 
+// QueryIssues runs the query against the issue store. AgentIds match the agent in issueAgentMap, so resolved
+// issues still match the agent that resolved them.
+func (rs *ResolutionService) QueryIssues(q IssueQuery) (IssuePage, error) {
+	return rs.issueService.QueryIssues(q)
+}
+
+// Search finds the issues whose subject, description, resolution or timeline messages best match the text among
+// the issues matching the filter, see IssueService.Search
+func (rs *ResolutionService) Search(text string, filter IssueQuery, limit int) ([]IssueHit, error) {
+	return rs.issueService.Search(text, filter, limit)
+}
+
+// UpdateIssue records progress on an assigned issue. Statuses driven by assignment, resolution and cancellation
+// (Assigned, Waitlisted, Reopened, Resolved, Cancelled) can only be reached through their own operations.
 func (rs *ResolutionService) UpdateIssue(issueId, resolution string, status models.IssueStatus) error {
-	rs.mutex.RLock()
-	defer rs.mutex.RUnlock()
-	if _, ok := rs.issueAgentMap[issueId]; !ok {
-		return fmt.Errorf("cannot update, issue not yet assigned to any agent")
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return ErrIssueNotFound
+	}
+	agentId, ok := rs.issueAgentMap[issueId]
+	if !ok {
+		return fmt.Errorf("cannot update, %w", ErrIssueNotAssigned)
+	}
+	switch status {
+	case models.Assigned, models.Waitlisted, models.Reopened, models.Resolved, models.Cancelled:
+		return fmt.Errorf("cannot update to %s, %w", status, ErrStatusManaged)
 	}
-	return rs.issueService.UpdateIssue(issueId, resolution, status)
+	if err := checkTransition(issue, status); err != nil {
+		return err
+	}
+	if resolution == "" {
+		return fmt.Errorf("%w: resolution cannot be empty", models.ErrInvalidIssue)
+	}
+	return rs.commit(&events.Event{Type: events.IssueStatusChanged, IssueId: issueId, Author: agentId, Status: status, Resolution: resolution})
 }
 
 func (rs *ResolutionService) ResolveIssue(issueId, resolution string) error {
@@ -80,29 +484,120 @@ func (rs *ResolutionService) ResolveIssue(issueId, resolution string) error {
 
 	issue := rs.issueService.GetIssue(issueId)
 	if issue == nil {
-		return fmt.Errorf("issue not found")
+		return ErrIssueNotFound
 	}
 
-	agentId := rs.issueAgentMap[issueId]
-	newIssueAssigned, err := rs.AgentService.ResolveIssue(agentId, resolution)
-	if err != nil {
-		fmt.Println("error occurred - ResolveIssue", err)
+	agentId, ok := rs.issueAgentMap[issueId]
+	if !ok {
+		return fmt.Errorf("cannot resolve, %w", ErrIssueNotAssigned)
+	}
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil {
+		return ErrAgentNotFound
+	}
+	if err := checkTransition(issue, models.Resolved); err != nil {
 		return err
 	}
-	if newIssueAssigned != nil {
-		rs.issueAgentMap[newIssueAssigned.Id] = agentId
-		fmt.Printf("Issue %s has been assigned to agent %s from the pendingIssues Queue \n", newIssueAssigned.Id, agentId)
+	// the agent only resolves issues it is working on, so guard against resolving one still pending in its queue
+	if !agent.HasActiveIssue(issueId) {
+		return fmt.Errorf("cannot resolve, %w", ErrIssueNotActive)
+	}
+	if resolution == "" {
+		return fmt.Errorf("%w: resolution cannot be empty", models.ErrInvalidIssue)
 	}
 
-	err = rs.issueService.UpdateIssue(issueId, resolution, models.Resolved)
+	resolved := &events.Event{Type: events.IssueResolved, IssueId: issueId, AgentId: agentId, Author: agentId, Resolution: resolution}
+	next := agent.PeekPendingIssue()
+	if next == nil {
+		if err := rs.commit(resolved); err != nil {
+			return err
+		}
+		return rs.drainUnassigned()
+	}
+	err := rs.commit(resolved, &events.Event{Type: events.PendingIssuePromoted, IssueId: next.Id, AgentId: agentId})
 	if err != nil {
-		fmt.Println("error occurred - UpdateIssue", err)
 		return err
 	}
+	fmt.Printf("Issue %s has been assigned to agent %s from the pendingIssues Queue \n", next.Id, agentId)
+	return nil
+}
 
+// CancelIssue withdraws an open issue that no longer needs work, wherever it is: with an agent working on it, in an
+// agent's queue or in the unassigned pool. An agent that was working on it picks up its next pending one.
+func (rs *ResolutionService) CancelIssue(issueId, reason string) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	issue := rs.issueService.GetIssue(issueId)
+	if issue == nil {
+		return ErrIssueNotFound
+	}
+	if err := checkTransition(issue, models.Cancelled); err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("%w: reason cannot be empty", models.ErrInvalidIssue)
+	}
+
+	cancelled := &events.Event{Type: events.IssueCancelled, IssueId: issueId, Reason: reason}
+	holder, active := rs.holderOf(issueId)
+	if holder != nil {
+		cancelled.AgentId = holder.Id
+	}
+	if !active {
+		return rs.commit(cancelled)
+	}
+	next := holder.PeekPendingIssue()
+	if next == nil {
+		if err := rs.commit(cancelled); err != nil {
+			return err
+		}
+		// the agent has a free slot now
+		return rs.drainUnassigned()
+	}
+	if err := rs.commit(cancelled, &events.Event{Type: events.PendingIssuePromoted, IssueId: next.Id, AgentId: holder.Id}); err != nil {
+		return err
+	}
+	fmt.Printf("Issue %s has been assigned to agent %s from the pendingIssues Queue \n", next.Id, holder.Id)
+	return nil
+}
+
+// GetUnassignedIssues returns the issues parked for the given type, the next one to be picked up first
+func (rs *ResolutionService) GetUnassignedIssues(issueType models.IssueType) []*models.Issue {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+	return rs.unassigned.Issues(issueType)
+}
+
+// drainUnassigned routes the parked issues, most urgent first, through the assignment strategy the way AssignIssue
+// would. An issue only leaves the pool for an agent the strategy picks that can start on it right away, queuing it
+// behind a busy agent's work would only move the wait.
+func (rs *ResolutionService) drainUnassigned() error {
+	for _, issueType := range rs.unassigned.Types() {
+		for _, issue := range rs.unassigned.Issues(issueType) {
+			// issues of the same type may still route differently, by the tier of their customer
+			target := rs.route(issue)
+			if target == nil || !target.IsAvailable() {
+				continue
+			}
+			if err := rs.commit(&events.Event{Type: events.IssueAssigned, IssueId: issue.Id, AgentId: target.Id}); err != nil {
+				return err
+			}
+			fmt.Printf("Issue %s has been assigned to agent %s from the unassigned pool \n", issue.Id, target.Id)
+		}
+	}
+	return nil
+}
+
+// checkTransition validates a transition up front so that only valid transitions reach the event log
+func checkTransition(issue *models.Issue, to models.IssueStatus) error {
+	from := issue.GetStatus()
+	if !models.CanTransition(from, to) {
+		return &models.InvalidTransitionError{IssueId: issue.Id, From: from, To: to}
+	}
 	return nil
 }
 
-func (rs *ResolutionService) ViewAgentsWorkHistory() map[string][]string {
+func (rs *ResolutionService) ViewAgentsWorkHistory() map[string]WorkHistory {
 	return rs.AgentService.GetWorkHistory()
 }
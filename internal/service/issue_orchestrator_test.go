@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"iss/internal/models"
+	"testing"
+)
+
+func mustAssign(t *testing.T, rs *ResolutionService, issueId string) (string, bool) {
+	t.Helper()
+	agentId, waitListed, err := rs.AssignIssue(issueId)
+	if err != nil {
+		t.Fatalf("AssignIssue(%s): %v", issueId, err)
+	}
+	return agentId, waitListed
+}
+
+func wantStatus(t *testing.T, rs *ResolutionService, issueId string, want models.IssueStatus) {
+	t.Helper()
+	if got := rs.issueService.GetIssue(issueId).GetStatus(); got != want {
+		t.Errorf("issue %s is %s, want %s", issueId, got, want)
+	}
+}
+
+func TestAssignWaitlistAndPromote(t *testing.T) {
+	rs := newTestService(t)
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	first := mustCreateIssue(t, rs, "T1", models.Payment)
+	second := mustCreateIssue(t, rs, "T2", models.Payment)
+
+	if got, waitListed := mustAssign(t, rs, first); got != agentId || waitListed {
+		t.Fatalf("first issue went to %q waitlisted=%v, want %s right away", got, waitListed, agentId)
+	}
+	wantStatus(t, rs, first, models.Assigned)
+	if got, waitListed := mustAssign(t, rs, second); got != agentId || !waitListed {
+		t.Fatalf("second issue went to %q waitlisted=%v, want the queue of %s", got, waitListed, agentId)
+	}
+	wantStatus(t, rs, second, models.Waitlisted)
+
+	if err := rs.ResolveIssue(second, "refunded"); !errors.Is(err, ErrIssueNotAssigned) {
+		t.Errorf("resolving a queued issue = %v, want %v", err, ErrIssueNotAssigned)
+	}
+	if err := rs.ResolveIssue(first, "refunded"); err != nil {
+		t.Fatalf("ResolveIssue: %v", err)
+	}
+	wantStatus(t, rs, first, models.Resolved)
+	wantStatus(t, rs, second, models.Assigned)
+	agent := rs.AgentService.GetAgent(agentId)
+	if !agent.HasActiveIssue(second) || len(agent.GetPendingIssues()) != 0 {
+		t.Errorf("queued issue %s was not promoted into the freed slot", second)
+	}
+}
+
+func TestAssignRejectsIssueAlreadyWithAnAgent(t *testing.T) {
+	rs := newTestService(t)
+	mustAddAgent(t, rs, "agent", 1, models.Payment)
+	first := mustCreateIssue(t, rs, "T1", models.Payment)
+	second := mustCreateIssue(t, rs, "T2", models.Payment)
+	mustAssign(t, rs, first)
+	mustAssign(t, rs, second)
+
+	for _, id := range []string{first, second} {
+		if _, _, err := rs.AssignIssue(id); !errors.Is(err, ErrIssueAlreadyAssigned) {
+			t.Errorf("assigning issue %s again = %v, want %v", id, err, ErrIssueAlreadyAssigned)
+		}
+	}
+	// the queued issue was booked once, so the promotion that follows the resolution goes through
+	if err := rs.ResolveIssue(first, "refunded"); err != nil {
+		t.Fatalf("ResolveIssue after a rejected assignment: %v", err)
+	}
+	wantStatus(t, rs, second, models.Assigned)
+}
+
+func TestAssignWithoutAgents(t *testing.T) {
+	rs := newTestService(t)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+
+	if _, _, err := rs.AssignIssue(issueId); !errors.Is(err, ErrNoAgentAvailable) {
+		t.Errorf("AssignIssue without agents = %v, want %v", err, ErrNoAgentAvailable)
+	}
+	wantStatus(t, rs, issueId, models.Created)
+}
+
+func TestPendingQueueServesHigherPriorityFirst(t *testing.T) {
+	rs := newTestService(t)
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	active := mustCreateIssue(t, rs, "T1", models.Payment)
+	low := mustCreateIssue(t, rs, "T2", models.Payment)
+	urgent, err := rs.CreateIssue("T3", "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P0)
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	for _, id := range []string{active, low, urgent} {
+		mustAssign(t, rs, id)
+	}
+
+	if err := rs.ResolveIssue(active, "refunded"); err != nil {
+		t.Fatalf("ResolveIssue: %v", err)
+	}
+	if !rs.AgentService.GetAgent(agentId).HasActiveIssue(urgent) {
+		t.Errorf("the P0 issue %s was not promoted ahead of %s", urgent, low)
+	}
+	wantStatus(t, rs, low, models.Waitlisted)
+}
+
+func TestCancelIssue(t *testing.T) {
+	rs := newTestService(t)
+	agentId := mustAddAgent(t, rs, "agent", 1, models.Payment)
+	active := mustCreateIssue(t, rs, "T1", models.Payment)
+	queued := mustCreateIssue(t, rs, "T2", models.Payment)
+	next := mustCreateIssue(t, rs, "T3", models.Payment)
+	unassigned := mustCreateIssue(t, rs, "T4", models.Payment)
+	for _, id := range []string{active, queued, next} {
+		mustAssign(t, rs, id)
+	}
+
+	if err := rs.CancelIssue(queued, "customer withdrew the complaint"); err != nil {
+		t.Fatalf("CancelIssue(queued): %v", err)
+	}
+	wantStatus(t, rs, queued, models.Cancelled)
+	agent := rs.AgentService.GetAgent(agentId)
+	if pending := agent.GetPendingIssues(); len(pending) != 1 || pending[0].Id != next {
+		t.Errorf("agent queue is %v after cancelling %s, want [%s]", issueIds(pending), queued, next)
+	}
+
+	if err := rs.CancelIssue(active, "refund went through"); err != nil {
+		t.Fatalf("CancelIssue(active): %v", err)
+	}
+	wantStatus(t, rs, active, models.Cancelled)
+	wantStatus(t, rs, next, models.Assigned)
+	if agent.HasActiveIssue(active) || !agent.HasActiveIssue(next) {
+		t.Errorf("agent did not pick up %s in place of the cancelled %s", next, active)
+	}
+
+	if err := rs.CancelIssue(unassigned, "raised twice"); err != nil {
+		t.Fatalf("CancelIssue(unassigned): %v", err)
+	}
+	wantStatus(t, rs, unassigned, models.Cancelled)
+
+	if err := rs.CancelIssue(active, "again"); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("cancelling a cancelled issue = %v, want %v", err, models.ErrInvalidTransition)
+	}
+	if err := rs.CancelIssue(next, ""); !errors.Is(err, models.ErrInvalidIssue) {
+		t.Errorf("cancelling without a reason = %v, want %v", err, models.ErrInvalidIssue)
+	}
+}
+
+func TestReassignRequiresTargetAcceptingWork(t *testing.T) {
+	rs := newTestService(t)
+	source := mustAddAgent(t, rs, "source", 1, models.Payment)
+	target := mustAddAgent(t, rs, "target", 1, models.Payment)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+	if agentId, _ := mustAssign(t, rs, issueId); agentId != source {
+		t.Fatalf("issue went to %s, want %s", agentId, source)
+	}
+
+	for _, presence := range []models.Presence{models.Away, models.Offline, models.OnLeave} {
+		if _, err := rs.SetAgentPresence(target, presence); err != nil {
+			t.Fatalf("SetAgentPresence(%s): %v", presence, err)
+		}
+		if _, err := rs.ReassignIssue(issueId, target, "rebalancing"); !errors.Is(err, ErrAgentUnavailable) {
+			t.Errorf("reassigning to a target %s = %v, want %v", presence, err, ErrAgentUnavailable)
+		}
+	}
+	if _, err := rs.SetAgentPresence(target, models.Online); err != nil {
+		t.Fatalf("SetAgentPresence(Online): %v", err)
+	}
+	if waitListed, err := rs.ReassignIssue(issueId, target, "rebalancing"); err != nil || waitListed {
+		t.Fatalf("reassigning to an Online target = waitlisted %v, %v", waitListed, err)
+	}
+	if !rs.AgentService.GetAgent(target).HasActiveIssue(issueId) {
+		t.Errorf("issue %s is not active with %s after the reassignment", issueId, target)
+	}
+}
+
+func TestUnassignedPoolDrainsThroughStrategy(t *testing.T) {
+	rs := NewResolutionService(NewIssueService(nil), NewAgentService(nil), NewRoundRobinStrategy(), nil)
+	first := mustAddAgent(t, rs, "first", 1, models.Payment)
+	second := mustAddAgent(t, rs, "second", 2, models.Payment)
+	if _, err := rs.SetAgentPresence(second, models.Away); err != nil {
+		t.Fatalf("SetAgentPresence(Away): %v", err)
+	}
+	active := mustCreateIssue(t, rs, "T1", models.Payment)
+	parked := mustCreateIssue(t, rs, "T2", models.Payment)
+	for _, id := range []string{active, parked} {
+		if agentId, _ := mustAssign(t, rs, id); agentId != first {
+			t.Fatalf("issue %s went to %s, want %s", id, agentId, first)
+		}
+	}
+	// nobody else is accepting work, so the queued issue is parked when its agent goes offline
+	if moved, err := rs.SetAgentPresence(first, models.Offline); err != nil || moved[parked] != "" {
+		t.Fatalf("SetAgentPresence(Offline) moved %v, %v, want %s parked", moved, err, parked)
+	}
+
+	if _, err := rs.SetAgentPresence(second, models.Online); err != nil {
+		t.Fatalf("SetAgentPresence(Online): %v", err)
+	}
+	if !rs.AgentService.GetAgent(second).HasActiveIssue(parked) {
+		t.Fatalf("parked issue %s was not picked up by %s", parked, second)
+	}
+	if _, err := rs.SetAgentPresence(first, models.Online); err != nil {
+		t.Fatalf("SetAgentPresence(Online): %v", err)
+	}
+	if err := rs.ResolveIssue(active, "refunded"); err != nil {
+		t.Fatalf("ResolveIssue: %v", err)
+	}
+	// the issue that left the pool took second's turn in the rotation, so the next one goes to first
+	next := mustCreateIssue(t, rs, "T3", models.Payment)
+	if agentId, _ := mustAssign(t, rs, next); agentId != first {
+		t.Errorf("next issue went to %s, want %s whose turn it is", agentId, first)
+	}
+}
+
+func TestLeastLoadedParksIssuesWithoutExperts(t *testing.T) {
+	rs := NewResolutionService(NewIssueService(nil), NewAgentService(nil), NewLeastLoadedStrategy(false), nil)
+	mustAddAgent(t, rs, "gold", 1, models.Gold)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+
+	if agentId, waitListed := mustAssign(t, rs, issueId); agentId != "" || !waitListed {
+		t.Fatalf("payment issue went to %q, want it parked without an agent", agentId)
+	}
+	wantStatus(t, rs, issueId, models.Waitlisted)
+
+	// the first payment expert drains the pool
+	expert := mustAddAgent(t, rs, "payment", 1, models.Payment)
+	if !rs.AgentService.GetAgent(expert).HasActiveIssue(issueId) {
+		t.Errorf("parked issue %s was not picked up by the new expert %s", issueId, expert)
+	}
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	m "iss/internal/models"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+)
+
+// IssueSortField is the issue field a query orders its results by
+type IssueSortField string
+
+const (
+	SortById              IssueSortField = "id"
+	SortByTxnId           IssueSortField = "txn_id"
+	SortByType            IssueSortField = "type"
+	SortByPriority        IssueSortField = "priority"
+	SortBySubject         IssueSortField = "subject"
+	SortByDescription     IssueSortField = "description"
+	SortByEmail           IssueSortField = "email"
+	SortByStatus          IssueSortField = "status"
+	SortByResolution      IssueSortField = "resolution"
+	SortByReopenCount     IssueSortField = "reopen_count"
+	SortByCreatedAt       IssueSortField = "created_at"
+	SortByUpdatedAt       IssueSortField = "updated_at"
+	SortByFirstResponseAt IssueSortField = "first_response_at"
+	SortByResolvedAt      IssueSortField = "resolved_at"
+	SortByEscalatedAt     IssueSortField = "escalated_at"
+)
+
+// sortKey is the value an issue is ordered by, numeric fields use Int and text fields use Str
+type sortKey struct {
+	Int int64  `json:"i,omitempty"`
+	Str string `json:"s,omitempty"`
+}
+
+func (k sortKey) compare(other sortKey) int {
+	if c := cmp.Compare(k.Int, other.Int); c != 0 {
+		return c
+	}
+	return strings.Compare(k.Str, other.Str)
+}
+
+// sortKeys extracts the sort key of every IssueSortField. Priorities order by urgency, so P0 sorts last
+// ascending, types and statuses order by name.
+var sortKeys = map[IssueSortField]func(issue *m.Issue) sortKey{
+	SortById:          func(issue *m.Issue) sortKey { return sortKey{Str: issue.Id} },
+	SortByTxnId:       func(issue *m.Issue) sortKey { return sortKey{Str: issue.TxnId} },
+	SortByType:        func(issue *m.Issue) sortKey { return sortKey{Str: issue.Type.String()} },
+	SortByPriority:    func(issue *m.Issue) sortKey { return sortKey{Int: int64(issue.Priority)} },
+	SortBySubject:     func(issue *m.Issue) sortKey { return sortKey{Str: issue.Subject} },
+	SortByDescription: func(issue *m.Issue) sortKey { return sortKey{Str: issue.Description} },
+	SortByEmail:       func(issue *m.Issue) sortKey { return sortKey{Str: issue.Email} },
+	SortByStatus:      func(issue *m.Issue) sortKey { return sortKey{Str: issue.GetStatus().String()} },
+	SortByResolution:  func(issue *m.Issue) sortKey { return sortKey{Str: issue.Resolution} },
+	SortByReopenCount: func(issue *m.Issue) sortKey { return sortKey{Int: int64(issue.GetReopenCount())} },
+	SortByCreatedAt:   func(issue *m.Issue) sortKey { return sortKey{Int: issue.CreatedAt} },
+	SortByUpdatedAt:   func(issue *m.Issue) sortKey { return sortKey{Int: issue.GetUpdatedAt()} },
+	SortByFirstResponseAt: func(issue *m.Issue) sortKey {
+		_, firstResponseAt, _, _ := issue.GetTimestamps()
+		return sortKey{Int: firstResponseAt}
+	},
+	SortByResolvedAt: func(issue *m.Issue) sortKey {
+		_, _, resolvedAt, _ := issue.GetTimestamps()
+		return sortKey{Int: resolvedAt}
+	},
+	SortByEscalatedAt: func(issue *m.Issue) sortKey {
+		_, _, _, escalatedAt := issue.GetTimestamps()
+		return sortKey{Int: escalatedAt}
+	},
+}
+
+// TimeRange matches unix times in [From, To), a zero bound leaves that side open
+type TimeRange struct {
+	From int64
+	To   int64
+}
+
+func (r TimeRange) contains(at int64) bool {
+	return (r.From == 0 || at >= r.From) && (r.To == 0 || at < r.To)
+}
+
+// IssueQuery selects, orders and pages issues. Every set field must match, a list matches when the issue has any of
+// its values. Text matches ignore case.
+type IssueQuery struct {
+	Ids         []string
+	TxnIds      []string
+	Emails      []string
+	Types       []m.IssueType
+	Priorities  []m.Priority
+	Statuses    []m.IssueStatus
+	AgentIds    []string // the agent the issue is assigned to, or was resolved by
+	CustomerIds []string
+	Reopened    *bool
+
+	CreatedAt TimeRange
+	UpdatedAt TimeRange
+
+	SubjectContains     string
+	SubjectPrefix       string
+	DescriptionContains string
+	DescriptionPrefix   string
+
+	SortBy     IssueSortField // defaults to SortByCreatedAt, ties are broken by id
+	Descending bool
+	Limit      int    // defaults to DefaultQueryLimit, at most MaxQueryLimit
+	Cursor     string // the NextCursor of the previous page, empty for the first page
+}
+
+// IssuePage is one page of a query, NextCursor is empty on the last page
+type IssuePage struct {
+	Issues     []*m.Issue `json:"issues"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// queryCursor is the position after the last issue of a page. It carries the sort key rather than the issue
+// so that a page stays stable when the issue it ended on changes or goes away.
+type queryCursor struct {
+	SortBy     IssueSortField `json:"f"`
+	Descending bool           `json:"d,omitempty"`
+	Key        sortKey        `json:"k"`
+	Id         string         `json:"id"`
+}
+
+func (c queryCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*queryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c queryCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return &c, nil
+}
+
+// normalize fills in the defaults and rejects queries that cannot be run
+func (q *IssueQuery) normalize() (*queryCursor, error) {
+	if q.SortBy == "" {
+		q.SortBy = SortByCreatedAt
+	}
+	if _, ok := sortKeys[q.SortBy]; !ok {
+		return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, q.SortBy)
+	}
+	switch {
+	case q.Limit < 0:
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit == 0:
+		q.Limit = DefaultQueryLimit
+	case q.Limit > MaxQueryLimit:
+		q.Limit = MaxQueryLimit
+	}
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	cursor, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.SortBy != q.SortBy || cursor.Descending != q.Descending {
+		return nil, fmt.Errorf("%w: cursor belongs to a query with a different order", ErrInvalidQuery)
+	}
+	return cursor, nil
+}
+
+// compare orders two issues by the query's sort field and then by id, so that no two issues are equal
+func (q *IssueQuery) compare(a sortKey, aId string, b sortKey, bId string) int {
+	c := a.compare(b)
+	if c == 0 {
+		c = cmp.Compare(aId, bId)
+	}
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+// matches expects agentOf to return the agent of an issue, empty when it has none
+func (q *IssueQuery) matches(issue *m.Issue, agentOf func(issueId string) string) bool {
+	if len(q.Ids) > 0 && !slices.Contains(q.Ids, issue.Id) {
+		return false
+	}
+	if len(q.TxnIds) > 0 && !slices.Contains(q.TxnIds, issue.TxnId) {
+		return false
+	}
+	if len(q.Emails) > 0 && !slices.ContainsFunc(q.Emails, func(email string) bool { return strings.EqualFold(email, issue.Email) }) {
+		return false
+	}
+	if len(q.Types) > 0 && !slices.Contains(q.Types, issue.Type) {
+		return false
+	}
+	if len(q.Priorities) > 0 && !slices.Contains(q.Priorities, issue.Priority) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, issue.GetStatus()) {
+		return false
+	}
+	if q.Reopened != nil && *q.Reopened != (issue.GetReopenCount() > 0) {
+		return false
+	}
+	if !q.CreatedAt.contains(issue.CreatedAt) || !q.UpdatedAt.contains(issue.GetUpdatedAt()) {
+		return false
+	}
+	if !containsFold(issue.Subject, q.SubjectContains) || !hasPrefixFold(issue.Subject, q.SubjectPrefix) {
+		return false
+	}
+	if !containsFold(issue.Description, q.DescriptionContains) || !hasPrefixFold(issue.Description, q.DescriptionPrefix) {
+		return false
+	}
+	if len(q.AgentIds) > 0 && !slices.Contains(q.AgentIds, agentOf(issue.Id)) {
+		return false
+	}
+	if len(q.CustomerIds) > 0 && !slices.Contains(q.CustomerIds, issue.CustomerId) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// plan narrows the query down through the most selective index its IN lists allow, nil when none of them is set
+func (q *IssueQuery) plan(ix *issueIndex) lookup {
+	var ids, txnIds, emails, types, statuses, agents, customers lookup
+	if len(q.Ids) > 0 {
+		ids = idLookup(q.Ids)
+	}
+	if len(q.TxnIds) > 0 {
+		txnIds = lookupIn(ix.byTxnId, q.TxnIds)
+	}
+	if len(q.Emails) > 0 {
+		lowered := make([]string, 0, len(q.Emails))
+		for _, email := range q.Emails {
+			lowered = append(lowered, strings.ToLower(email))
+		}
+		emails = lookupIn(ix.byEmail, lowered)
+	}
+	if len(q.Types) > 0 {
+		types = lookupIn(ix.byType, q.Types)
+	}
+	if len(q.Statuses) > 0 {
+		statuses = lookupIn(ix.byStatus, q.Statuses)
+	}
+	if len(q.AgentIds) > 0 {
+		agents = lookupIn(ix.byAgent, q.AgentIds)
+	}
+	if len(q.CustomerIds) > 0 {
+		customers = lookupIn(ix.byCustomer, q.CustomerIds)
+	}
+	return plan(ids, txnIds, emails, types, statuses, agents, customers)
+}
+
+// QueryIssues returns the page of issues matching the query that follows its cursor. The most selective
+// index picks the issues the rest of the query is checked on.
+func (is *IssueService) QueryIssues(q IssueQuery) (IssuePage, error) {
+	cursor, err := q.normalize()
+	if err != nil {
+		return IssuePage{}, err
+	}
+	key := sortKeys[q.SortBy]
+
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	type keyed struct {
+		issue *m.Issue
+		key   sortKey
+	}
+	matched := make([]keyed, 0)
+	for _, issue := range is.candidates(q.plan(is.index)) {
+		if !q.matches(issue, is.index.agentOf) {
+			continue
+		}
+		k := key(issue)
+		if cursor != nil && q.compare(k, issue.Id, cursor.Key, cursor.Id) <= 0 {
+			continue
+		}
+		matched = append(matched, keyed{issue: issue, key: k})
+	}
+	slices.SortFunc(matched, func(a, b keyed) int { return q.compare(a.key, a.issue.Id, b.key, b.issue.Id) })
+
+	page := IssuePage{Issues: make([]*m.Issue, 0, min(len(matched), q.Limit))}
+	for _, k := range matched[:min(len(matched), q.Limit)] {
+		page.Issues = append(page.Issues, k.issue)
+	}
+	if len(matched) > q.Limit {
+		last := matched[q.Limit-1]
+		page.NextCursor = queryCursor{SortBy: q.SortBy, Descending: q.Descending, Key: last.key, Id: last.issue.Id}.encode()
+	}
+	return page, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	m "iss/internal/models"
+	"iss/internal/search"
+)
+
+// IssueHit is an issue matching a full-text search, the higher the score the better it matches
+type IssueHit struct {
+	Issue *m.Issue `json:"issue"`
+	Score float64  `json:"score"`
+}
+
+// putText indexes the words of the issue's subject, description, resolution note and timeline messages,
+// expects is.mu to be held
+func (is *IssueService) putText(issue *m.Issue) {
+	text := []string{issue.Subject, issue.Description, issue.Resolution}
+	for _, entry := range issue.GetTimeline() {
+		if entry.Kind.IsMessage() {
+			text = append(text, entry.Body)
+		}
+	}
+	is.text.Put(issue.Id, text...)
+}
+
+// Search ranks the issues matching the filter by how well they match the text with BM25, best match first. An
+// issue matches when it holds any of the words of the text, after stemming. Results are ordered by relevance
+// only, so the filter cannot carry a sort order or cursor. The limit defaults to DefaultQueryLimit and is
+// capped at MaxQueryLimit.
+func (is *IssueService) Search(text string, filter IssueQuery, limit int) ([]IssueHit, error) {
+	if len(search.Tokenize(text)) == 0 {
+		return nil, fmt.Errorf("%w: search needs at least one word that is not a stop word", ErrInvalidQuery)
+	}
+	if filter.SortBy != "" || filter.Descending || filter.Cursor != "" {
+		return nil, fmt.Errorf("%w: search results are ordered by relevance", ErrInvalidQuery)
+	}
+	switch {
+	case limit < 0:
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case limit == 0:
+		limit = DefaultQueryLimit
+	case limit > MaxQueryLimit:
+		limit = MaxQueryLimit
+	}
+
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	keep := func(id string) bool {
+		issue := is.repo.Get(id)
+		return issue != nil && filter.matches(issue, is.index.agentOf)
+	}
+	hits := is.text.Search(text, keep, limit)
+	issueHits := make([]IssueHit, 0, len(hits))
+	for _, hit := range hits {
+		issueHits = append(issueHits, IssueHit{Issue: is.repo.Get(hit.Id), Score: hit.Score})
+	}
+	return issueHits, nil
+}
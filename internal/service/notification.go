@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"iss/internal/events"
+	m "iss/internal/models"
+	"iss/internal/notify"
+)
+
+// notificationWorkers and notificationBuffer size the bus subscription of NotificationService.Subscribe
+const (
+	notificationWorkers = 2
+	notificationBuffer  = 256
+)
+
+// NotificationService emails customers about their issues as they move through the workflow, in the customer's
+// locale, unless they opted out
+type NotificationService struct {
+	notifier  notify.Notifier
+	templates *NotificationTemplates
+	issues    *IssueService
+	agents    *AgentService
+	customers *CustomerService
+}
+
+// NewNotificationService notifies the customers of the resolution service, with DefaultNotificationTemplates when
+// no templates are given
+func NewNotificationService(rs *ResolutionService, notifier notify.Notifier, templates *NotificationTemplates) *NotificationService {
+	if templates == nil {
+		templates = DefaultNotificationTemplates()
+	}
+	return &NotificationService{
+		notifier:  notifier,
+		templates: templates,
+		issues:    rs.issueService,
+		agents:    rs.AgentService,
+		customers: rs.customers,
+	}
+}
+
+// Subscribe sends the notifications from asynchronous workers so that a slow mail server does not hold up the
+// workflow, the notifications about an issue go out in order
+func (ns *NotificationService) Subscribe(bus *events.Bus) *events.Subscription {
+	return bus.SubscribeAsync(ns.Handle, notificationWorkers, notificationBuffer)
+}
+
+// Handle emails the customer behind the event's issue when there is a template for the event. Issues raised before
+// customers existed are notified at their email in DefaultLocale.
+func (ns *NotificationService) Handle(e events.Event) {
+	issue := ns.issues.GetIssue(e.IssueId)
+	if issue == nil {
+		return
+	}
+	to, locale := issue.Email, m.DefaultLocale
+	if customer := ns.customers.GetCustomer(issue.CustomerId); customer != nil {
+		if customer.IsOptedOut() {
+			return
+		}
+		to, locale = customer.GetContact()
+	}
+	tmpl, ok := ns.templates.lookup(e.Type, issue.Type, locale)
+	if !ok {
+		return
+	}
+
+	data := NotificationData{
+		IssueId:    issue.Id,
+		TxnId:      issue.TxnId,
+		Subject:    issue.Subject,
+		IssueType:  issue.Type.String(),
+		Resolution: e.Resolution,
+	}
+	if agent := ns.agents.GetAgent(e.AgentId); agent != nil {
+		data.AgentName = agent.Name
+	}
+	msg, err := tmpl.render(to, data)
+	if err == nil {
+		err = ns.notifier.Notify(msg)
+	}
+	if err != nil {
+		fmt.Printf("Notification of %s about issue %s could not be sent: %v \n", e.Type, e.IssueId, err)
+	}
+}
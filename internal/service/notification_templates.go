@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"iss/internal/events"
+	m "iss/internal/models"
+	"iss/internal/notify"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// NotificationData is what notification templates are rendered with
+type NotificationData struct {
+	IssueId    string
+	TxnId      string
+	Subject    string
+	IssueType  string
+	AgentName  string // the agent the issue went to, on IssueAssigned and PendingIssuePromoted
+	Resolution string // on IssueResolved
+}
+
+// templateKey picks a template, Unknown as the issue type stands for every type without a template of its own
+type templateKey struct {
+	event     events.EventType
+	issueType m.IssueType
+	locale    string
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NotificationTemplates holds the text/template subject and body of the email sent for a lifecycle event, per issue
+// type and locale. Events without a template are not notified.
+type NotificationTemplates struct {
+	templates map[templateKey]messageTemplate
+	mu        sync.RWMutex
+}
+
+func NewNotificationTemplates() *NotificationTemplates {
+	return &NotificationTemplates{templates: make(map[templateKey]messageTemplate)}
+}
+
+// Add registers the subject and body for the event about issues of the type, Unknown for any type, in the locale.
+// Templates referring to anything but the fields of NotificationData are rejected.
+func (t *NotificationTemplates) Add(eventType events.EventType, issueType m.IssueType, locale, subject, body string) error {
+	name := fmt.Sprintf("%s/%s/%s", eventType, issueType, locale)
+	tmpl := messageTemplate{}
+	var err error
+	if tmpl.subject, err = template.New(name + "/subject").Parse(subject); err != nil {
+		return err
+	}
+	if tmpl.body, err = template.New(name + "/body").Parse(body); err != nil {
+		return err
+	}
+	if _, err := tmpl.render("", NotificationData{}); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.templates[templateKey{event: eventType, issueType: issueType, locale: strings.ToLower(locale)}] = tmpl
+	return nil
+}
+
+// lookup prefers the customer's locale over a template specific to the issue type. A locale like pt-BR falls back to
+// its language and then to DefaultLocale.
+func (t *NotificationTemplates) lookup(eventType events.EventType, issueType m.IssueType, locale string) (messageTemplate, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	locale = strings.ToLower(locale)
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, language, m.DefaultLocale} {
+		for _, it := range []m.IssueType{issueType, m.Unknown} {
+			if tmpl, ok := t.templates[templateKey{event: eventType, issueType: it, locale: candidate}]; ok {
+				return tmpl, true
+			}
+		}
+	}
+	return messageTemplate{}, false
+}
+
+func (tmpl messageTemplate) render(to string, data NotificationData) (notify.Message, error) {
+	var subject, body strings.Builder
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return notify.Message{}, err
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return notify.Message{}, err
+	}
+	return notify.Message{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
+
+// defaultTemplate is one entry of DefaultNotificationTemplates
+type defaultTemplate struct {
+	event     events.EventType
+	issueType m.IssueType
+	locale    string
+	subject   string
+	body      string
+}
+
+var defaultTemplates = []defaultTemplate{
+	{events.IssueCreated, m.Unknown, "en", "[{{.IssueId}}] We have received your request",
+		"Hello,\n\nThanks for getting in touch about \"{{.Subject}}\". Your request has been logged as issue {{.IssueId}} and one of our agents will pick it up shortly.\n\nPlease quote {{.IssueId}} if you write to us again about transaction {{.TxnId}}.\n"},
+	{events.IssueCreated, m.Payment, "en", "[{{.IssueId}}] We are looking into your payment",
+		"Hello,\n\nWe know a failed payment is worrying. Your request about transaction {{.TxnId}} has been logged as issue {{.IssueId}} and our payments team will look into it shortly.\n\nPlease quote {{.IssueId}} if you write to us again about this payment.\n"},
+	{events.IssueAssigned, m.Unknown, "en", "[{{.IssueId}}] An agent is working on your request",
+		"Hello,\n\n{{.AgentName}} is now working on issue {{.IssueId}} about \"{{.Subject}}\". We will write again as soon as it is resolved.\n"},
+	{events.PendingIssuePromoted, m.Unknown, "en", "[{{.IssueId}}] An agent is working on your request",
+		"Hello,\n\n{{.AgentName}} is now working on issue {{.IssueId}} about \"{{.Subject}}\". We will write again as soon as it is resolved.\n"},
+	{events.IssueResolved, m.Unknown, "en", "[{{.IssueId}}] Your request has been resolved",
+		"Hello,\n\nIssue {{.IssueId}} about \"{{.Subject}}\" has been resolved:\n\n{{.Resolution}}\n\nIf the problem persists, write to us quoting {{.IssueId}} and we will reopen it.\n"},
+
+	{events.IssueCreated, m.Unknown, "es", "[{{.IssueId}}] Hemos recibido tu solicitud",
+		"Hola:\n\nGracias por escribirnos sobre \"{{.Subject}}\". Tu solicitud quedó registrada como la incidencia {{.IssueId}} y uno de nuestros agentes la atenderá en breve.\n\nIndica {{.IssueId}} si vuelves a escribirnos sobre la transacción {{.TxnId}}.\n"},
+	{events.IssueCreated, m.Payment, "es", "[{{.IssueId}}] Estamos revisando tu pago",
+		"Hola:\n\nSabemos que un pago fallido preocupa. Tu solicitud sobre la transacción {{.TxnId}} quedó registrada como la incidencia {{.IssueId}} y nuestro equipo de pagos la revisará en breve.\n\nIndica {{.IssueId}} si vuelves a escribirnos sobre este pago.\n"},
+	{events.IssueAssigned, m.Unknown, "es", "[{{.IssueId}}] Un agente está trabajando en tu solicitud",
+		"Hola:\n\n{{.AgentName}} está trabajando en la incidencia {{.IssueId}} sobre \"{{.Subject}}\". Te escribiremos de nuevo en cuanto esté resuelta.\n"},
+	{events.PendingIssuePromoted, m.Unknown, "es", "[{{.IssueId}}] Un agente está trabajando en tu solicitud",
+		"Hola:\n\n{{.AgentName}} está trabajando en la incidencia {{.IssueId}} sobre \"{{.Subject}}\". Te escribiremos de nuevo en cuanto esté resuelta.\n"},
+	{events.IssueResolved, m.Unknown, "es", "[{{.IssueId}}] Tu solicitud ha sido resuelta",
+		"Hola:\n\nLa incidencia {{.IssueId}} sobre \"{{.Subject}}\" ha sido resuelta:\n\n{{.Resolution}}\n\nSi el problema continúa, escríbenos indicando {{.IssueId}} y la reabriremos.\n"},
+}
+
+// DefaultNotificationTemplates acknowledges new issues, tells the customer when an agent starts working on one
+// and sends the resolution, in English and Spanish
+func DefaultNotificationTemplates() *NotificationTemplates {
+	t := NewNotificationTemplates()
+	for _, d := range defaultTemplates {
+		if err := t.Add(d.event, d.issueType, d.locale, d.subject, d.body); err != nil {
+			panic(fmt.Sprintf("default notification template %s: %v", d.event, err))
+		}
+	}
+	return t
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"iss/internal/events"
+	"iss/internal/models"
+	"iss/internal/notify"
+	"strings"
+	"testing"
+)
+
+// recordingNotifier keeps the messages instead of sending them, NotificationService.Handle is called directly
+type recordingNotifier struct {
+	sent []notify.Message
+}
+
+func (n *recordingNotifier) Notify(msg notify.Message) error {
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+// notifyCustomer raises an issue for a new customer in the locale and returns what the IssueCreated event sends
+func notifyCustomer(t *testing.T, locale string, issueType models.IssueType) []notify.Message {
+	t.Helper()
+	rs := newTestService(t)
+	email := "customer-" + locale + "@test.com"
+	if _, err := rs.AddCustomer([]string{email}, "", models.Standard, locale, 0); err != nil {
+		t.Fatalf("AddCustomer(%s): %v", locale, err)
+	}
+	issueId, err := rs.CreateIssue("T1", "Payment Failed", "money debited", email, issueType, models.P2)
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	notifier := &recordingNotifier{}
+	NewNotificationService(rs, notifier, nil).Handle(events.Event{Type: events.IssueCreated, IssueId: issueId})
+	return notifier.sent
+}
+
+func TestNotificationLocaleFallback(t *testing.T) {
+	tests := []struct {
+		locale    string
+		issueType models.IssueType
+		subject   string
+	}{
+		{"es", models.Payment, "Estamos revisando tu pago"},
+		{"es-MX", models.Payment, "Estamos revisando tu pago"},        // region falls back to its language
+		{"es-MX", models.Gold, "Hemos recibido tu solicitud"},         // type without a template of its own
+		{"fr-FR", models.Payment, "We are looking into your payment"}, // language without templates
+		{"en", models.MutualFund, "We have received your request"},
+	}
+	for _, tt := range tests {
+		sent := notifyCustomer(t, tt.locale, tt.issueType)
+		if len(sent) != 1 {
+			t.Errorf("%s %s: %d messages sent, want 1", tt.locale, tt.issueType, len(sent))
+			continue
+		}
+		if !strings.Contains(sent[0].Subject, tt.subject) {
+			t.Errorf("%s %s: subject %q, want the %q template", tt.locale, tt.issueType, sent[0].Subject, tt.subject)
+		}
+	}
+}
+
+func TestNotificationRespectsOptOut(t *testing.T) {
+	rs := newTestService(t)
+	customerId, err := rs.AddCustomer([]string{"customer@test.com", "other@test.com"}, "", models.Standard, "en", 0)
+	if err != nil {
+		t.Fatalf("AddCustomer: %v", err)
+	}
+	// raised from the customer's second address, notifications go to the primary one
+	issueId, err := rs.CreateIssue("T1", "Payment Failed", "money debited", "other@test.com", models.Payment, models.P2)
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	notifier := &recordingNotifier{}
+	ns := NewNotificationService(rs, notifier, nil)
+	created := events.Event{Type: events.IssueCreated, IssueId: issueId}
+
+	if err := rs.SetCustomerOptOut(customerId, true); err != nil {
+		t.Fatalf("SetCustomerOptOut: %v", err)
+	}
+	ns.Handle(created)
+	if len(notifier.sent) != 0 {
+		t.Fatalf("opted out customer was sent %+v", notifier.sent)
+	}
+
+	if err := rs.SetCustomerOptOut(customerId, false); err != nil {
+		t.Fatalf("SetCustomerOptOut: %v", err)
+	}
+	ns.Handle(created)
+	if len(notifier.sent) != 1 || notifier.sent[0].To != "customer@test.com" {
+		t.Fatalf("customer opted back in was sent %+v, want one message to customer@test.com", notifier.sent)
+	}
+}
+
+func TestNotificationSkipsEventsWithoutTemplate(t *testing.T) {
+	rs := newTestService(t)
+	issueId := mustCreateIssue(t, rs, "T1", models.Payment)
+	notifier := &recordingNotifier{}
+	NewNotificationService(rs, notifier, nil).Handle(events.Event{Type: events.IssueCommented, IssueId: issueId})
+	if len(notifier.sent) != 0 {
+		t.Errorf("event without a template was notified: %+v", notifier.sent)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+	"sort"
+	"time"
+)
+
+// SetAgentPresence changes whether the agent takes new work. An agent going Offline or OnLeave hands its pending
+// issues, most urgent first, to the agents chosen by the assignment strategy, issues nobody can take are parked in
+// the unassigned pool. Its active issues stay with it. The returned map holds the new agent of every moved issue,
+// empty for parked ones. An agent coming back Online lets the parked issues be routed again, see drainUnassigned.
+func (rs *ResolutionService) SetAgentPresence(agentId string, presence models.Presence) (map[string]string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	agent := rs.AgentService.GetAgent(agentId)
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+	if agent.IsDeactivated() {
+		return nil, fmt.Errorf("cannot change presence of %s, %w", agentId, ErrAgentDeactivated)
+	}
+	if presence < models.Online || presence > models.OnLeave {
+		return nil, fmt.Errorf("%w: unknown presence %d", models.ErrInvalidAgent, presence)
+	}
+	return rs.changePresence(agent, presence)
+}
+
+// changePresence expects rs.mutex to be held
+func (rs *ResolutionService) changePresence(agent *models.Agent, presence models.Presence) (map[string]string, error) {
+	moved := make(map[string]string)
+	if agent.GetPresence() == presence {
+		return moved, nil
+	}
+	if err := rs.commit(&events.Event{Type: events.AgentPresenceChanged, AgentId: agent.Id, Presence: presence}); err != nil {
+		return moved, err
+	}
+	fmt.Printf("Agent %s is now %s \n", agent.Id, presence)
+
+	if presence.AcceptsWork() {
+		return moved, rs.drainUnassigned()
+	}
+	if presence.KeepsQueue() {
+		return moved, nil
+	}
+	reason := fmt.Sprintf("agent %s went %s", agent.Id, presence)
+	return moved, rs.handOver(agent, events.PendingIssueMoved, agent.GetPendingIssues(), reason, moved)
+}
+
+// handOver routes each issue away from the agent through the assignment strategy, recording its new agent in
+// moved, empty when nobody could take it and it was parked. The agent must already be out of the indexes so
+// that the strategy only sees the agents that remain. Expects rs.mutex to be held.
+func (rs *ResolutionService) handOver(agent *models.Agent, eventType events.EventType, issues []*models.Issue, reason string, moved map[string]string) error {
+	for _, issue := range append([]*models.Issue{}, issues...) {
+		e := &events.Event{Type: eventType, IssueId: issue.Id, Reason: reason, Expedite: rs.expedites(issue)}
+		if target := rs.route(issue); target != nil {
+			e.AgentId = target.Id
+		}
+		if err := rs.commit(e); err != nil {
+			return err
+		}
+		if e.AgentId == "" {
+			fmt.Printf("Issue %s has been moved from agent %s to the unassigned pool \n", issue.Id, agent.Id)
+		} else {
+			fmt.Printf("Issue %s has been moved from agent %s to agent %s \n", issue.Id, agent.Id, e.AgentId)
+		}
+		moved[issue.Id] = e.AgentId
+	}
+	return nil
+}
+
+// SetAgentShifts replaces the agent's shift schedule, an empty schedule leaves its presence to SetAgentPresence only
+func (rs *ResolutionService) SetAgentShifts(agentId string, shifts []models.Shift) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.AgentService.GetAgent(agentId) == nil {
+		return ErrAgentNotFound
+	}
+	for _, shift := range shifts {
+		if err := shift.Validate(); err != nil {
+			return err
+		}
+	}
+	return rs.commit(&events.Event{Type: events.AgentShiftsChanged, AgentId: agentId, Shifts: shifts})
+}
+
+// ApplyShifts takes Online agents whose shift is over Offline and Offline agents whose shift started back Online.
+// Agents without shifts, deactivated, Away or OnLeave are left alone. It returns the IDs of the agents that changed presence.
+func (rs *ResolutionService) ApplyShifts(now time.Time) ([]string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	changed := make([]string, 0)
+	agents := rs.AgentService.GetAgents()
+	ids := make([]string, 0, len(agents))
+	for id := range agents {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return agentIdLess(ids[i], ids[j]) })
+	for _, id := range ids {
+		agent := agents[id]
+		if len(agent.GetShifts()) == 0 || agent.IsDeactivated() {
+			continue
+		}
+		onShift := agent.OnShift(now)
+		var presence models.Presence
+		switch {
+		case agent.GetPresence() == models.Online && !onShift:
+			presence = models.Offline
+		case agent.GetPresence() == models.Offline && onShift:
+			presence = models.Online
+		default:
+			continue
+		}
+		if _, err := rs.changePresence(agent, presence); err != nil {
+			return changed, err
+		}
+		changed = append(changed, id)
+	}
+	return changed, nil
+}
+
+// ShiftMonitor periodically brings agents' presence in line with their shift schedules
+type ShiftMonitor struct {
+	rs       *ResolutionService
+	interval time.Duration
+}
+
+func NewShiftMonitor(rs *ResolutionService, interval time.Duration) *ShiftMonitor {
+	return &ShiftMonitor{rs: rs, interval: interval}
+}
+
+// Run blocks, applying the shifts on every tick, until the context is cancelled
+func (m *ShiftMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.rs.ApplyShifts(time.Now()); err != nil {
+				fmt.Println("error occurred - ApplyShifts", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"iss/internal/events"
+	"iss/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLATarget is the contractual time allowed for an issue, both measured from Issue.SLAStartedAt so that a reopened
+// issue gets its full budget again
+type SLATarget struct {
+	FirstResponse time.Duration `json:"first_response"`
+	Resolution    time.Duration `json:"resolution"`
+}
+
+// SLAPolicy holds the SLA targets per issue type and priority. Types or priorities without
+// a target of their own fall back to the default target.
+type SLAPolicy struct {
+	defaultTarget SLATarget
+	targets       map[models.IssueType]map[models.Priority]SLATarget
+	// an open issue is at risk once less than this fraction of its time budget is left
+	atRiskFraction float64
+	mu             sync.RWMutex
+}
+
+func NewSLAPolicy(defaultTarget SLATarget, atRiskFraction float64) *SLAPolicy {
+	return &SLAPolicy{
+		defaultTarget:  defaultTarget,
+		targets:        make(map[models.IssueType]map[models.Priority]SLATarget),
+		atRiskFraction: atRiskFraction,
+	}
+}
+
+// DefaultSLAPolicy has payments on the tightest targets and gold on the loosest
+func DefaultSLAPolicy() *SLAPolicy {
+	p := NewSLAPolicy(SLATarget{FirstResponse: 8 * time.Hour, Resolution: 72 * time.Hour}, 0.2)
+	base := map[models.IssueType]SLATarget{
+		models.Payment:    {FirstResponse: 30 * time.Minute, Resolution: 8 * time.Hour},
+		models.MutualFund: {FirstResponse: 2 * time.Hour, Resolution: 24 * time.Hour},
+		models.Insurance:  {FirstResponse: 4 * time.Hour, Resolution: 48 * time.Hour},
+		models.Gold:       {FirstResponse: 8 * time.Hour, Resolution: 72 * time.Hour},
+	}
+	// every step up in priority halves the time budget
+	for issueType, target := range base {
+		for priority := models.P3; priority <= models.P0; priority++ {
+			scale := time.Duration(1 << (priority - models.P3))
+			p.SetTarget(issueType, priority, SLATarget{FirstResponse: target.FirstResponse / scale, Resolution: target.Resolution / scale})
+		}
+	}
+	return p
+}
+
+func (p *SLAPolicy) SetTarget(issueType models.IssueType, priority models.Priority, target SLATarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.targets[issueType]; !ok {
+		p.targets[issueType] = make(map[models.Priority]SLATarget)
+	}
+	p.targets[issueType][priority] = target
+}
+
+func (p *SLAPolicy) TargetFor(issueType models.IssueType, priority models.Priority) SLATarget {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if target, ok := p.targets[issueType][priority]; ok {
+		return target
+	}
+	return p.defaultTarget
+}
+
+type SLAState int
+
+const (
+	SLAOnTrack SLAState = iota
+	SLAAtRisk
+	SLABreached
+)
+
+func (s SLAState) String() string {
+	switch s {
+	case SLAAtRisk:
+		return "AtRisk"
+	case SLABreached:
+		return "Breached"
+	default:
+		return "OnTrack"
+	}
+}
+
+func (s SLAState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SLAStatus describes how an issue is doing against its SLA, deadlines are unix times
+type SLAStatus struct {
+	IssueId               string   `json:"issue_id"`
+	FirstResponseDue      int64    `json:"first_response_due"`
+	ResolutionDue         int64    `json:"resolution_due"`
+	FirstResponseBreached bool     `json:"first_response_breached"`
+	ResolutionBreached    bool     `json:"resolution_breached"`
+	State                 SLAState `json:"state"`
+}
+
+// Evaluate computes the SLA status of the issue at the given unix time. A deadline is breached when the
+// milestone happened after it, or has not happened yet and the deadline has passed.
+func (p *SLAPolicy) Evaluate(issue *models.Issue, now int64) SLAStatus {
+	_, firstResponseAt, resolvedAt, _ := issue.GetTimestamps()
+	startedAt := issue.SLAStartedAt()
+	target := p.TargetFor(issue.Type, issue.Priority)
+	status := SLAStatus{
+		IssueId:          issue.Id,
+		FirstResponseDue: startedAt + int64(target.FirstResponse/time.Second),
+		ResolutionDue:    startedAt + int64(target.Resolution/time.Second),
+	}
+	status.FirstResponseBreached = missed(firstResponseAt, status.FirstResponseDue, now)
+	status.ResolutionBreached = missed(resolvedAt, status.ResolutionDue, now)
+
+	switch {
+	case status.FirstResponseBreached || status.ResolutionBreached:
+		status.State = SLABreached
+	case p.atRisk(firstResponseAt, startedAt, status.FirstResponseDue, now) || p.atRisk(resolvedAt, startedAt, status.ResolutionDue, now):
+		status.State = SLAAtRisk
+	}
+	return status
+}
+
+func missed(doneAt, due, now int64) bool {
+	if doneAt != 0 {
+		return doneAt > due
+	}
+	return now > due
+}
+
+func (p *SLAPolicy) atRisk(doneAt, startedAt, due, now int64) bool {
+	if doneAt != 0 {
+		return false
+	}
+	budget := float64(due - startedAt)
+	return float64(due-now) < budget*p.atRiskFraction
+}
+
+// WithSLAPolicy replaces the DefaultSLAPolicy used to evaluate issues
+func WithSLAPolicy(policy *SLAPolicy) Option {
+	return func(rs *ResolutionService) {
+		rs.slaPolicy = policy
+	}
+}
+
+// GetSLAStatuses returns the SLA status of every open issue in the given state, most urgent deadline first. Resolved,
+// closed and cancelled issues have no deadline left to meet and are not reported.
+func (rs *ResolutionService) GetSLAStatuses(state SLAState) []SLAStatus {
+	now := time.Now().Unix()
+	statuses := make([]SLAStatus, 0)
+	for _, issue := range rs.issueService.GetIssues(nil) {
+		if isTerminal(issue.GetStatus()) {
+			continue
+		}
+		status := rs.slaPolicy.Evaluate(issue, now)
+		if status.State == state {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ResolutionDue != statuses[j].ResolutionDue {
+			return statuses[i].ResolutionDue < statuses[j].ResolutionDue
+		}
+		return statuses[i].IssueId < statuses[j].IssueId
+	})
+	return statuses
+}
+
+func (rs *ResolutionService) GetAtRiskIssues() []SLAStatus {
+	return rs.GetSLAStatuses(SLAAtRisk)
+}
+
+func (rs *ResolutionService) GetBreachedIssues() []SLAStatus {
+	return rs.GetSLAStatuses(SLABreached)
+}
+
+// EscalateAtRiskIssues escalates every open issue that is at risk or already breached and has not been
+// escalated yet, once its lifecycle allows it to move to Escalated, an issue that was never assigned is left to the
+// assignment. With reassign set, an escalated issue still waiting in a busy agent's queue is moved to
+// the agent chosen by the assignment strategy when that agent can start on it right away.
+func (rs *ResolutionService) EscalateAtRiskIssues(reassign bool) ([]string, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	now := time.Now().Unix()
+	escalated := make([]string, 0)
+	for _, issue := range rs.issueService.GetIssues(nil) {
+		_, _, resolvedAt, escalatedAt := issue.GetTimestamps()
+		if resolvedAt != 0 || escalatedAt != 0 || !models.CanTransition(issue.GetStatus(), models.Escalated) {
+			continue
+		}
+		status := rs.slaPolicy.Evaluate(issue, now)
+		if status.State == SLAOnTrack {
+			continue
+		}
+
+		e := &events.Event{Type: events.IssueEscalated, IssueId: issue.Id, Reason: fmt.Sprintf("SLA %s", status.State)}
+		if reassign && issue.GetStatus() == models.Waitlisted {
+			target := rs.route(issue)
+			if target != nil && target.IsAvailable() {
+				e.AgentId = target.Id
+			}
+		}
+		if err := rs.commit(e); err != nil {
+			return escalated, err
+		}
+		fmt.Printf("Issue %s escalated: %s \n", issue.Id, e.Reason)
+		escalated = append(escalated, issue.Id)
+	}
+	return escalated, nil
+}
+
+func isTerminal(status models.IssueStatus) bool {
+	return status == models.Resolved || status == models.Closed || status == models.Cancelled
+}
+
+// SLAMonitor periodically escalates issues that are about to breach their SLA
+type SLAMonitor struct {
+	rs       *ResolutionService
+	interval time.Duration
+	reassign bool
+}
+
+func NewSLAMonitor(rs *ResolutionService, interval time.Duration, reassign bool) *SLAMonitor {
+	return &SLAMonitor{rs: rs, interval: interval, reassign: reassign}
+}
+
+// Run blocks, checking the SLAs on every tick, until the context is cancelled
+func (m *SLAMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.rs.EscalateAtRiskIssues(m.reassign); err != nil {
+				fmt.Println("error occurred - EscalateAtRiskIssues", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"iss/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvaluateMeasuresFromCreation(t *testing.T) {
+	policy := NewSLAPolicy(SLATarget{FirstResponse: time.Hour, Resolution: 10 * time.Hour}, 0.2)
+	issue, _ := models.NewIssue("I1", "T1", "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2)
+	issue.CreatedAt = 1000
+	hour := int64(time.Hour / time.Second)
+
+	tests := []struct {
+		now  int64
+		want SLAState
+	}{
+		{1000 + hour/2, SLAOnTrack},
+		{1000 + hour - hour/10, SLAAtRisk},
+		{1000 + 2*hour, SLABreached},
+	}
+	for _, tt := range tests {
+		if got := policy.Evaluate(issue, tt.now); got.State != tt.want {
+			t.Errorf("Evaluate at %d = %s, want %s", tt.now, got.State, tt.want)
+		}
+	}
+}
+
+func TestEvaluateRestartsClockOnReopen(t *testing.T) {
+	policy := NewSLAPolicy(SLATarget{FirstResponse: time.Hour, Resolution: 10 * time.Hour}, 0.2)
+	issue, _ := models.NewIssue("I1", "T1", "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2)
+	hour := int64(time.Hour / time.Second)
+	issue.CreatedAt = 1000
+	for i, status := range []models.IssueStatus{models.Assigned, models.InProgress, models.Resolved} {
+		if err := issue.SetStatusAt(status, 1000+int64(i+1)*60); err != nil {
+			t.Fatalf("SetStatusAt(%s): %v", status, err)
+		}
+	}
+	reopenedAt := 1000 + 48*hour
+	if err := issue.ReopenAt("still debited", reopenedAt); err != nil {
+		t.Fatalf("ReopenAt: %v", err)
+	}
+
+	status := policy.Evaluate(issue, reopenedAt+hour/2)
+	if status.FirstResponseDue != reopenedAt+hour || status.ResolutionDue != reopenedAt+10*hour {
+		t.Errorf("reopened issue is due at %d and %d, want %d and %d", status.FirstResponseDue, status.ResolutionDue, reopenedAt+hour, reopenedAt+10*hour)
+	}
+	if status.State != SLAOnTrack {
+		t.Errorf("issue reopened half an hour ago is %s, want %s", status.State, SLAOnTrack)
+	}
+	if status := policy.Evaluate(issue, reopenedAt+2*hour); !status.FirstResponseBreached {
+		t.Errorf("reopened issue without a new first response is not breached two hours later")
+	}
+}
+
+func TestSLAReportsAndEscalatesOpenIssuesOnly(t *testing.T) {
+	rs := newTestService(t)
+	mustAddAgent(t, rs, "agent", 1, models.Payment)
+	assigned := mustCreateIssue(t, rs, "T1", models.Payment)
+	mustAssign(t, rs, assigned)
+	created := mustCreateIssue(t, rs, "T2", models.Payment)
+	cancelled := mustCreateIssue(t, rs, "T3", models.Payment)
+	if err := rs.CancelIssue(cancelled, "raised twice"); err != nil {
+		t.Fatalf("CancelIssue: %v", err)
+	}
+	// every issue was raised two days ago, long past its deadlines
+	for _, id := range []string{assigned, created, cancelled} {
+		rs.issueService.GetIssue(id).CreatedAt -= 48 * int64(time.Hour/time.Second)
+	}
+
+	if breached := issueIdsOf(rs.GetBreachedIssues()); !slices.Equal(breached, []string{assigned, created}) {
+		t.Errorf("breached issues %v, want the open %s and %s", breached, assigned, created)
+	}
+	escalated, err := rs.EscalateAtRiskIssues(false)
+	if err != nil {
+		t.Fatalf("EscalateAtRiskIssues: %v", err)
+	}
+	// a Created issue cannot be escalated, it is left to the assignment rather than escalated again on every run
+	if !slices.Equal(escalated, []string{assigned}) {
+		t.Errorf("escalated %v, want only %s", escalated, assigned)
+	}
+	wantStatus(t, rs, created, models.Created)
+	if escalated, _ := rs.EscalateAtRiskIssues(false); len(escalated) != 0 {
+		t.Errorf("second run escalated %v again", escalated)
+	}
+}
+
+func issueIdsOf(statuses []SLAStatus) []string {
+	ids := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		ids = append(ids, status.IssueId)
+	}
+	slices.Sort(ids)
+	return ids
+}
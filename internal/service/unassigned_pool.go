@@ -0,0 +1,67 @@
+package service
+
+import (
+	"iss/internal/models"
+	"slices"
+	"sort"
+)
+
+// UnassignedPool holds issues that no eligible agent could take, one queue per IssueType ordered like
+// an agent's pending queue. It is guarded by the ResolutionService mutex.
+type UnassignedPool struct {
+	queues map[models.IssueType][]*models.Issue
+}
+
+func NewUnassignedPool() *UnassignedPool {
+	return &UnassignedPool{
+		queues: make(map[models.IssueType][]*models.Issue),
+	}
+}
+
+func (p *UnassignedPool) Add(issue *models.Issue) {
+	queue := p.queues[issue.Type]
+	pos := sort.Search(len(queue), func(i int) bool {
+		return issue.PendingBefore(queue[i])
+	})
+	p.queues[issue.Type] = slices.Insert(queue, pos, issue)
+}
+
+func (p *UnassignedPool) Remove(issueId string) bool {
+	for issueType, queue := range p.queues {
+		for i, issue := range queue {
+			if issue.Id == issueId {
+				p.queues[issueType] = slices.Delete(queue, i, i+1)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *UnassignedPool) Contains(issueId string) bool {
+	for _, queue := range p.queues {
+		for _, issue := range queue {
+			if issue.Id == issueId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Issues returns the parked issues of the given type, the next one to be picked up first
+func (p *UnassignedPool) Issues(issueType models.IssueType) []*models.Issue {
+	return append([]*models.Issue{}, p.queues[issueType]...)
+}
+
+// Types returns the issue types that have parked issues
+func (p *UnassignedPool) Types() []models.IssueType {
+	types := make([]models.IssueType, 0, len(p.queues))
+	for issueType, queue := range p.queues {
+		if len(queue) > 0 {
+			types = append(types, issueType)
+		}
+	}
+	slices.Sort(types)
+	return types
+}
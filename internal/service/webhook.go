@@ -0,0 +1,435 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iss/internal/events"
+	m "iss/internal/models"
+	"iss/internal/repository"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookIdPrefix tells webhook IDs apart from issue, agent and customer IDs
+	WebhookIdPrefix = "W"
+	// DeliveryIdPrefix starts the ID of every delivery, a dead letter keeps the ID of its delivery
+	DeliveryIdPrefix = "D"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" followed by the hex HMAC-SHA256 of the timestamp,
+// a dot and the body, keyed with the webhook's secret, see Sign.
+const (
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookWorkers and webhookBuffer size the bus subscription of Subscribe. Workers only make the first attempt at a
+// delivery, retries wait in the retry queue, so a dead endpoint holds a worker up for one client timeout per event.
+const (
+	webhookWorkers = 4
+	webhookBuffer  = 256
+)
+
+// RetryPolicy bounds how often a delivery is attempted, the delay doubles after every failed attempt up to MaxDelay
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 6, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+}
+
+// Delay is how long to wait after the given failed attempt, counting from 1
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// WebhookPayload is the JSON body of a delivery
+type WebhookPayload struct {
+	Id    string        `json:"id"` // the delivery ID, also sent in DeliveryHeader so that receivers can drop replays they have seen
+	Event events.Event  `json:"event"`
+	Issue *WebhookIssue `json:"issue,omitempty"`
+}
+
+// WebhookIssue identifies the issue an event is about, the event itself tells what happened to it
+type WebhookIssue struct {
+	Id         string      `json:"id"`
+	TxnId      string      `json:"txn_id"`
+	Type       m.IssueType `json:"type"`
+	Email      string      `json:"email"`
+	CustomerId string      `json:"customer_id,omitempty"`
+}
+
+type WebhookOption func(ws *WebhookService)
+
+// WithHTTPClient replaces the client deliveries are posted with, which times out after 10 seconds
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(ws *WebhookService) {
+		ws.client = client
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) WebhookOption {
+	return func(ws *WebhookService) {
+		ws.retry = policy
+	}
+}
+
+// WebhookService keeps webhook subscriptions and delivers the lifecycle events published on an events.Bus to them.
+// Deliveries that still fail once the RetryPolicy gives up, or that the endpoint refuses outright, end up in the
+// dead letter repository from where they can be replayed.
+type WebhookService struct {
+	repo        repository.WebhookRepository
+	deadLetters repository.DeadLetterRepository
+	issues      *IssueService
+	client      *http.Client
+	retry       RetryPolicy
+	ids         IDGenerator
+	deliveries  IDGenerator
+	// retries is the retry queue, the deliveries waiting for their next attempt by delivery ID
+	retries  map[string]*pendingRetry
+	retrying sync.WaitGroup
+	closed   bool
+	mu       sync.Mutex
+}
+
+// delivery is an event on its way to a webhook
+type delivery struct {
+	id        string
+	webhookId string
+	eventType string
+	issueId   string
+	body      []byte
+	attempts  int // made so far
+	lastError string
+}
+
+type pendingRetry struct {
+	delivery *delivery
+	timer    *time.Timer
+}
+
+// NewWebhookService keeps webhooks and dead letters in memory when no repositories are given
+func NewWebhookService(issues *IssueService, repo repository.WebhookRepository, deadLetters repository.DeadLetterRepository, opts ...WebhookOption) *WebhookService {
+	if repo == nil {
+		repo = repository.NewMemoryWebhookRepository()
+	}
+	if deadLetters == nil {
+		deadLetters = repository.NewMemoryDeadLetterRepository()
+	}
+	ws := &WebhookService{
+		repo:        repo,
+		deadLetters: deadLetters,
+		issues:      issues,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		retry:       DefaultRetryPolicy(),
+		ids:         NewSequenceGenerator(),
+		retries:     make(map[string]*pendingRetry),
+		// delivery IDs must not repeat across restarts, receivers use them to drop duplicates
+		deliveries: ULIDGenerator{},
+	}
+	for _, opt := range opts {
+		opt(ws)
+	}
+	for _, webhook := range repo.List() {
+		ws.ids.Observe(webhook.Id)
+	}
+	return ws
+}
+
+// SetIDGenerator replaces the sequence webhook IDs are taken from, the IDs of the stored webhooks are observed first
+func (ws *WebhookService) SetIDGenerator(ids IDGenerator) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, webhook := range ws.repo.List() {
+		ids.Observe(webhook.Id)
+	}
+	ws.ids = ids
+}
+
+// Subscribe delivers the events published on the bus from asynchronous workers, the events of an issue in order
+func (ws *WebhookService) Subscribe(bus *events.Bus) *events.Subscription {
+	return bus.SubscribeAsync(ws.Handle, webhookWorkers, webhookBuffer)
+}
+
+// RegisterWebhook subscribes the URL to the given event types, every type when none are given, about issues of the
+// given types, any issue when none are given. An empty secret is replaced by a random one.
+func (ws *WebhookService) RegisterWebhook(url string, eventTypes []string, issueTypes []m.IssueType, secret string) (*m.Webhook, error) {
+	parsed := make([]string, 0, len(eventTypes))
+	for _, name := range eventTypes {
+		eventType, err := events.ParseEventType(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", m.ErrInvalidWebhook, err)
+		}
+		if !slices.Contains(parsed, string(eventType)) {
+			parsed = append(parsed, string(eventType))
+		}
+	}
+	if secret == "" {
+		secret = newSecret()
+	}
+	webhook, err := m.NewWebhook("", url, parsed, issueTypes, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	webhook.Id = ws.ids.NewId(WebhookIdPrefix)
+	if err := ws.repo.Save(webhook); err != nil {
+		return nil, fmt.Errorf("error occurred while saving webhook %w", err)
+	}
+	return webhook, nil
+}
+
+func (ws *WebhookService) GetWebhook(id string) (*m.Webhook, error) {
+	webhook := ws.repo.Get(id)
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns every webhook, oldest first
+func (ws *WebhookService) ListWebhooks() []*m.Webhook {
+	webhooks := ws.repo.List()
+	sort.Slice(webhooks, func(i, j int) bool { return agentIdLess(webhooks[i].Id, webhooks[j].Id) })
+	return webhooks
+}
+
+// DeleteWebhook stops deliveries to the webhook, its dead letters are kept but can no longer be replayed
+func (ws *WebhookService) DeleteWebhook(id string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.repo.Get(id) == nil {
+		return ErrWebhookNotFound
+	}
+	return ws.repo.Delete(id)
+}
+
+// Handle makes the first attempt at delivering the event to every webhook subscribed to it. Failed attempts worth
+// retrying go to the retry queue rather than hold up the caller, so a retried delivery may reach the endpoint after
+// later events of its issue, receivers put them back in order by the event's seq.
+func (ws *WebhookService) Handle(e events.Event) {
+	var issue *m.Issue
+	if e.IssueId != "" {
+		issue = ws.issues.GetIssue(e.IssueId)
+	}
+	for _, webhook := range ws.ListWebhooks() {
+		if !webhookMatches(webhook, e, issue) {
+			continue
+		}
+		deliveryId := ws.deliveries.NewId(DeliveryIdPrefix)
+		body, err := json.Marshal(WebhookPayload{Id: deliveryId, Event: e, Issue: webhookIssue(issue)})
+		if err != nil {
+			fmt.Printf("Delivery %s of %s to webhook %s could not be encoded: %v \n", deliveryId, e.Type, webhook.Id, err)
+			continue
+		}
+		ws.attempt(&delivery{id: deliveryId, webhookId: webhook.Id, eventType: string(e.Type), issueId: e.IssueId, body: body})
+	}
+}
+
+// webhookMatches reports whether the webhook subscribed to the event, issue is nil for events about no issue
+func webhookMatches(webhook *m.Webhook, e events.Event, issue *m.Issue) bool {
+	if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, string(e.Type)) {
+		return false
+	}
+	if len(webhook.IssueTypes) == 0 {
+		return true
+	}
+	return issue != nil && slices.Contains(webhook.IssueTypes, issue.Type)
+}
+
+func webhookIssue(issue *m.Issue) *WebhookIssue {
+	if issue == nil {
+		return nil
+	}
+	return &WebhookIssue{Id: issue.Id, TxnId: issue.TxnId, Type: issue.Type, Email: issue.Email, CustomerId: issue.CustomerId}
+}
+
+// attempt makes the next attempt at the delivery, signed with the webhook's current secret. A failed attempt worth
+// retrying is put on the retry queue while the RetryPolicy allows another one, otherwise the delivery is dead lettered.
+func (ws *WebhookService) attempt(d *delivery) {
+	webhook := ws.repo.Get(d.webhookId)
+	if webhook == nil {
+		fmt.Printf("Delivery %s dropped, webhook %s has been deleted \n", d.id, d.webhookId)
+		return
+	}
+	d.attempts++
+	retry, err := ws.post(webhook, d.id, d.eventType, d.body)
+	if err == nil {
+		return
+	}
+	d.lastError = err.Error()
+	if retry && d.attempts < ws.retry.MaxAttempts && ws.scheduleRetry(d) {
+		return
+	}
+	fmt.Printf("Delivery %s of %s to webhook %s failed after %d attempts: %v \n", d.id, d.eventType, d.webhookId, d.attempts, err)
+	ws.deadLetter(d)
+}
+
+// scheduleRetry queues the delivery for another attempt once the RetryPolicy's delay has passed, it reports false
+// once the service is closed
+func (ws *WebhookService) scheduleRetry(d *delivery) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return false
+	}
+	ws.retrying.Add(1)
+	ws.retries[d.id] = &pendingRetry{delivery: d, timer: time.AfterFunc(ws.retry.Delay(d.attempts), func() {
+		defer ws.retrying.Done()
+		ws.mu.Lock()
+		delete(ws.retries, d.id)
+		ws.mu.Unlock()
+		ws.attempt(d)
+	})}
+	return true
+}
+
+func (ws *WebhookService) deadLetter(d *delivery) {
+	letter := &m.DeadLetter{
+		Id:        d.id,
+		WebhookId: d.webhookId,
+		EventType: d.eventType,
+		IssueId:   d.issueId,
+		Payload:   d.body,
+		Attempts:  d.attempts,
+		LastError: d.lastError,
+		FailedAt:  time.Now().Unix(),
+	}
+	if err := ws.deadLetters.Save(letter); err != nil {
+		fmt.Printf("Delivery %s could not be dead lettered: %v \n", d.id, err)
+	}
+}
+
+// Close stops retrying. The deliveries waiting in the retry queue are dead lettered, from where they can be replayed,
+// and attempts failing from now on are dead lettered right away. Close waits for the retries already under way.
+func (ws *WebhookService) Close() {
+	ws.mu.Lock()
+	ws.closed = true
+	stopped := make([]*delivery, 0, len(ws.retries))
+	for id, pending := range ws.retries {
+		// a timer that already fired is making its attempt, which dead letters the delivery should it fail
+		if pending.timer.Stop() {
+			stopped = append(stopped, pending.delivery)
+			delete(ws.retries, id)
+			ws.retrying.Done()
+		}
+	}
+	ws.mu.Unlock()
+	for _, d := range stopped {
+		ws.deadLetter(d)
+	}
+	ws.retrying.Wait()
+}
+
+// post makes a single delivery attempt, reporting whether a failed one is worth retrying. Timeouts, rate limiting
+// and server errors are, any other answer outside 2xx means the endpoint refused the delivery.
+func (ws *WebhookService) post(webhook *m.Webhook, deliveryId, eventType string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, deliveryId)
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("endpoint answered %s", resp.Status)
+}
+
+// Sign computes the SignatureHeader of a delivery, receivers recompute it with their copy of the secret and the
+// TimestampHeader, and should reject deliveries whose timestamp is too old
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSecret() string {
+	var b [32]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// DeadLetters returns the deliveries that failed for good, of every webhook when webhookId is empty, oldest first
+func (ws *WebhookService) DeadLetters(webhookId string) []*m.DeadLetter {
+	letters := make([]*m.DeadLetter, 0)
+	for _, letter := range ws.deadLetters.List() {
+		if webhookId == "" || letter.WebhookId == webhookId {
+			letters = append(letters, letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].FailedAt != letters[j].FailedAt {
+			return letters[i].FailedAt < letters[j].FailedAt
+		}
+		return letters[i].Id < letters[j].Id
+	})
+	return letters
+}
+
+// ReplayDeadLetter makes one more attempt at a failed delivery under its original delivery ID, signed anew with the
+// webhook's current secret. The dead letter is dropped once delivered and records the new error otherwise.
+func (ws *WebhookService) ReplayDeadLetter(id string) error {
+	letter := ws.deadLetters.Get(id)
+	if letter == nil {
+		return ErrDeadLetterNotFound
+	}
+	webhook := ws.repo.Get(letter.WebhookId)
+	if webhook == nil {
+		return fmt.Errorf("cannot replay %s, %w", id, ErrWebhookNotFound)
+	}
+	if _, err := ws.post(webhook, letter.Id, letter.EventType, letter.Payload); err != nil {
+		failed := *letter
+		failed.Attempts++
+		failed.LastError = err.Error()
+		failed.FailedAt = time.Now().Unix()
+		if err := ws.deadLetters.Save(&failed); err != nil {
+			return fmt.Errorf("error occurred while saving dead letter %w", err)
+		}
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	fmt.Printf("Delivery %s to webhook %s replayed \n", id, webhook.Id)
+	return ws.deadLetters.Delete(id)
+}
+
+// DiscardDeadLetter drops a failed delivery that is not going to be replayed
+func (ws *WebhookService) DiscardDeadLetter(id string) error {
+	if ws.deadLetters.Get(id) == nil {
+		return ErrDeadLetterNotFound
+	}
+	return ws.deadLetters.Delete(id)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"iss/internal/events"
+	"iss/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "s3cret"
+
+// endpoint is a webhook receiver answering with the statuses queued in answers, then 200
+type endpoint struct {
+	answers  []int
+	received []*http.Request
+	bodies   [][]byte
+	mu       sync.Mutex
+}
+
+func (ep *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.received = append(ep.received, r)
+	ep.bodies = append(ep.bodies, body)
+	if len(ep.answers) > 0 {
+		w.WriteHeader(ep.answers[0])
+		ep.answers = ep.answers[1:]
+	}
+}
+
+func (ep *endpoint) attempts() int {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return len(ep.received)
+}
+
+// newWebhookFixture registers a webhook posting to ep and creates the issue the test events are about
+func newWebhookFixture(t *testing.T, ep *endpoint, policy RetryPolicy) (*WebhookService, string) {
+	t.Helper()
+	srv := httptest.NewServer(ep)
+	t.Cleanup(srv.Close)
+	issues := NewIssueService(nil)
+	issueId, err := issues.CreateIssue("T1", "Payment Failed", "money debited", "customer@test.com", models.Payment, models.P2)
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	ws := NewWebhookService(issues, nil, nil, WithRetryPolicy(policy))
+	t.Cleanup(ws.Close)
+	if _, err := ws.RegisterWebhook(srv.URL, nil, nil, testSecret); err != nil {
+		t.Fatalf("RegisterWebhook: %v", err)
+	}
+	return ws, issueId
+}
+
+func fastRetries(maxAttempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	ep := &endpoint{}
+	ws, issueId := newWebhookFixture(t, ep, fastRetries(3))
+	ws.Handle(events.Event{Seq: 7, Type: events.IssueCreated, IssueId: issueId})
+
+	if ep.attempts() != 1 {
+		t.Fatalf("endpoint received %d deliveries, want 1", ep.attempts())
+	}
+	req, body := ep.received[0], ep.bodies[0]
+	timestamp, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("bad %s header: %v", TimestampHeader, err)
+	}
+	signature := req.Header.Get(SignatureHeader)
+	if !hmac.Equal([]byte(signature), []byte(Sign(testSecret, timestamp, body))) {
+		t.Errorf("signature %s does not verify with the webhook's secret", signature)
+	}
+	if hmac.Equal([]byte(signature), []byte(Sign("other", timestamp, body))) {
+		t.Errorf("signature %s verifies with another secret", signature)
+	}
+	if hmac.Equal([]byte(signature), []byte(Sign(testSecret, timestamp+1, body))) {
+		t.Errorf("signature %s does not cover the timestamp", signature)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("payload does not decode: %v", err)
+	}
+	if payload.Id != req.Header.Get(DeliveryHeader) || payload.Event.Seq != 7 || payload.Issue == nil || payload.Issue.Id != issueId {
+		t.Errorf("payload %+v does not describe delivery %s of event 7 about %s", payload, req.Header.Get(DeliveryHeader), issueId)
+	}
+	if req.Header.Get(EventHeader) != string(events.IssueCreated) {
+		t.Errorf("%s header is %q, want %s", EventHeader, req.Header.Get(EventHeader), events.IssueCreated)
+	}
+}
+
+func TestWebhookRetriesServerErrorsAndRateLimiting(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			ep := &endpoint{answers: []int{status, status}}
+			ws, issueId := newWebhookFixture(t, ep, fastRetries(3))
+			ws.Handle(events.Event{Seq: 1, Type: events.IssueCreated, IssueId: issueId})
+
+			waitFor(t, "the third attempt", func() bool { return ep.attempts() == 3 })
+			ws.Close()
+			if letters := ws.DeadLetters(""); len(letters) != 0 {
+				t.Errorf("delivery accepted on the third attempt was dead lettered: %+v", letters[0])
+			}
+			first := ep.received[0].Header.Get(DeliveryHeader)
+			for _, req := range ep.received[1:] {
+				if req.Header.Get(DeliveryHeader) != first {
+					t.Errorf("retry sent as delivery %s, want %s", req.Header.Get(DeliveryHeader), first)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusGone} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			ep := &endpoint{answers: []int{status}}
+			ws, issueId := newWebhookFixture(t, ep, fastRetries(3))
+			ws.Handle(events.Event{Seq: 1, Type: events.IssueCreated, IssueId: issueId})
+			ws.Close()
+
+			if ep.attempts() != 1 {
+				t.Errorf("endpoint refusing with %d was attempted %d times, want 1", status, ep.attempts())
+			}
+			letters := ws.DeadLetters("")
+			if len(letters) != 1 || letters[0].Attempts != 1 {
+				t.Fatalf("dead letters %+v, want one after a single attempt", letters)
+			}
+		})
+	}
+}
+
+func TestWebhookDeadLettersAfterMaxAttempts(t *testing.T) {
+	ep := &endpoint{answers: []int{500, 500, 500, 500, 500}}
+	ws, issueId := newWebhookFixture(t, ep, fastRetries(3))
+	ws.Handle(events.Event{Seq: 1, Type: events.IssueCreated, IssueId: issueId})
+
+	waitFor(t, "the dead letter", func() bool { return len(ws.DeadLetters("")) == 1 })
+	letter := ws.DeadLetters("")[0]
+	if letter.Attempts != 3 || ep.attempts() != 3 {
+		t.Errorf("dead lettered after %d attempts and %d requests, want 3", letter.Attempts, ep.attempts())
+	}
+	if letter.IssueId != issueId || letter.EventType != string(events.IssueCreated) || letter.LastError == "" {
+		t.Errorf("dead letter %+v does not describe the failed delivery", letter)
+	}
+	if letter.Id != ep.received[0].Header.Get(DeliveryHeader) || string(letter.Payload) != string(ep.bodies[0]) {
+		t.Errorf("dead letter does not keep the delivery ID and payload")
+	}
+}
+
+func TestHandleDoesNotWaitForRetries(t *testing.T) {
+	ep := &endpoint{answers: []int{503}}
+	ws, issueId := newWebhookFixture(t, ep, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	start := time.Now()
+	ws.Handle(events.Event{Seq: 1, Type: events.IssueCreated, IssueId: issueId})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Handle took %s, it must not wait for the retry", elapsed)
+	}
+	ws.mu.Lock()
+	queued := len(ws.retries)
+	ws.mu.Unlock()
+	if queued != 1 {
+		t.Fatalf("%d deliveries in the retry queue, want 1", queued)
+	}
+
+	// closing dead letters what is still waiting so that it can be replayed
+	ws.Close()
+	if letters := ws.DeadLetters(""); len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Errorf("dead letters after Close %+v, want the queued delivery after 1 attempt", letters)
+	}
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	ep := &endpoint{answers: []int{http.StatusBadRequest, http.StatusBadRequest}}
+	ws, issueId := newWebhookFixture(t, ep, fastRetries(3))
+	ws.Handle(events.Event{Seq: 1, Type: events.IssueCreated, IssueId: issueId})
+	letters := ws.DeadLetters("")
+	if len(letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(letters))
+	}
+	id := letters[0].Id
+
+	if err := ws.ReplayDeadLetter(id); !errors.Is(err, ErrDeliveryFailed) {
+		t.Fatalf("replay to a refusing endpoint = %v, want %v", err, ErrDeliveryFailed)
+	}
+	if letters := ws.DeadLetters(""); len(letters) != 1 || letters[0].Attempts != 2 {
+		t.Fatalf("dead letters after a failed replay %+v, want one with 2 attempts", letters)
+	}
+
+	if err := ws.ReplayDeadLetter(id); err != nil {
+		t.Fatalf("ReplayDeadLetter: %v", err)
+	}
+	if letters := ws.DeadLetters(""); len(letters) != 0 {
+		t.Errorf("dead letter kept after a successful replay: %+v", letters[0])
+	}
+	replayed := ep.received[2]
+	if replayed.Header.Get(DeliveryHeader) != id || string(ep.bodies[2]) != string(ep.bodies[0]) {
+		t.Errorf("replay sent as delivery %s, want the original %s and payload", replayed.Header.Get(DeliveryHeader), id)
+	}
+	timestamp, _ := strconv.ParseInt(replayed.Header.Get(TimestampHeader), 10, 64)
+	if replayed.Header.Get(SignatureHeader) != Sign(testSecret, timestamp, ep.bodies[2]) {
+		t.Errorf("replay is not signed with the webhook's secret")
+	}
+
+	if err := ws.ReplayDeadLetter(id); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("replaying a delivered dead letter = %v, want %v", err, ErrDeadLetterNotFound)
+	}
+}